@@ -0,0 +1,89 @@
+package httprequest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ByteBuffer is a []byte that decodes base64 JSON strings into its existing
+// backing array when it has enough capacity, rather than allocating a new
+// slice on every decode. Pre-allocate it with make(ByteBuffer, 0, n) and reuse
+// the decode target across calls to Do.
+type ByteBuffer []byte
+
+func (b ByteBuffer) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+
+	out := make([]byte, base64.StdEncoding.EncodedLen(len(b))+2)
+	out[0] = '"'
+	base64.StdEncoding.Encode(out[1:], b)
+	out[len(out)-1] = '"'
+	return out, nil
+}
+
+func (b *ByteBuffer) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(data, []byte("null")) {
+		*b = nil
+		return nil
+	}
+
+	if len(data) < 2 || data[0] != '"' || data[len(data)-1] != '"' {
+		return fmt.Errorf("unable to decode byte buffer: expected a base64 string")
+	}
+	src := data[1 : len(data)-1]
+	// Encoders may escape characters of the base64 alphabet, such as / as \/, which only a JSON string decode undoes
+	if bytes.IndexByte(src, '\\') >= 0 {
+		var unescaped string
+		err := json.Unmarshal(data, &unescaped)
+		if err != nil {
+			return fmt.Errorf("unable to decode byte buffer: %v", err)
+		}
+		src = []byte(unescaped)
+	}
+
+	buf := *b
+	if n := base64.StdEncoding.DecodedLen(len(src)); cap(buf) < n {
+		buf = make([]byte, n)
+	} else {
+		buf = buf[:n]
+	}
+
+	n, err := base64.StdEncoding.Decode(buf, src)
+	if err != nil {
+		return fmt.Errorf("unable to decode byte buffer: %v", err)
+	}
+
+	*b = buf[:n]
+	return nil
+}
+
+// maxPooledBufferSize caps the capacity of buffers returned to bodyBufferPool, so that one large response doesn't
+// keep its memory alive for every later request.
+const maxPooledBufferSize = 1 << 20
+
+// bodyBufferPool recycles the buffers response bodies are read into for decoding, which would otherwise be allocated
+// and grown for every response.
+var bodyBufferPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+func getBodyBuffer() *bytes.Buffer {
+	buf := bodyBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+// putBodyBuffer returns buf to the pool. Nothing may reference its contents afterwards.
+func putBodyBuffer(buf *bytes.Buffer) {
+	if buf.Cap() > maxPooledBufferSize {
+		return
+	}
+	bodyBufferPool.Put(buf)
+}
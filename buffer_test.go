@@ -0,0 +1,153 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type BlobResponse struct {
+	Name string     `json:"name"`
+	Data ByteBuffer `json:"data"`
+}
+
+func TestByteBuffer_UnmarshalJSON(t *testing.T) {
+	t.Run("Decodes into the pre-allocated buffer", func(t *testing.T) {
+		ctx := context.Background()
+		payload := []byte("some binary payload")
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, BlobResponse{Name: "blob", Data: payload}, nil)
+
+		buf := make(ByteBuffer, 0, 64)
+		out := BlobResponse{Data: buf}
+		_, err := New(http.MethodGet, testUrl, nil).Do(ctx, mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, "blob", out.Name)
+		assert.Equal(t, payload, []byte(out.Data))
+		assert.True(t, &buf[:1][0] == &out.Data[0], "expected decode to reuse the buffer")
+		mock.AssertExpectations(t)
+	})
+	t.Run("Allocates when the buffer is too small", func(t *testing.T) {
+		payload := []byte("some binary payload")
+		data, err := json.Marshal(BlobResponse{Data: payload})
+		require.NoError(t, err)
+
+		out := BlobResponse{Data: make(ByteBuffer, 0, 2)}
+		require.NoError(t, json.Unmarshal(data, &out))
+		assert.Equal(t, payload, []byte(out.Data))
+	})
+	t.Run("Null resets the buffer", func(t *testing.T) {
+		out := BlobResponse{Data: ByteBuffer("abc")}
+		require.NoError(t, json.Unmarshal([]byte(`{"data":null}`), &out))
+		assert.Nil(t, out.Data)
+	})
+	t.Run("Escaped characters are unescaped before decoding", func(t *testing.T) {
+		out := BlobResponse{Data: make(ByteBuffer, 0, 64)}
+		require.NoError(t, json.Unmarshal([]byte(`{"data":"\/\/\u002b\u002b"}`), &out))
+		assert.Equal(t, []byte{0xff, 0xff, 0xbe}, []byte(out.Data))
+	})
+	t.Run("Invalid base64 returns an error", func(t *testing.T) {
+		var out BlobResponse
+		require.Error(t, json.Unmarshal([]byte(`{"data":"not base64!"}`), &out))
+	})
+}
+
+func BenchmarkByteBuffer_UnmarshalJSON(b *testing.B) {
+	data, err := json.Marshal(BlobResponse{Data: make([]byte, 4096)})
+	require.NoError(b, err)
+
+	b.Run("[]byte", func(b *testing.B) {
+		b.ReportAllocs()
+		var out struct {
+			Data []byte `json:"data"`
+		}
+		for i := 0; i < b.N; i++ {
+			if err := json.Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("ByteBuffer", func(b *testing.B) {
+		b.ReportAllocs()
+		out := BlobResponse{Data: make(ByteBuffer, 0, 4096)}
+		for i := 0; i < b.N; i++ {
+			if err := json.Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func newBenchResponse(body []byte) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{HeaderContentType: {MIMEApplicationJson}},
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: -1,
+	}
+}
+
+func TestRequestBuilder_unmarshalResponse_bufferReuse(t *testing.T) {
+	type Document struct {
+		Name  string          `json:"name"`
+		Extra json.RawMessage `json:"extra"`
+	}
+
+	first := []byte(`{"name":"` + strings.Repeat("a", 2048) + `","extra":{"id":1}}`)
+	second := []byte(`{"name":"` + strings.Repeat("b", 4096) + `","extra":{"id":2}}`)
+	builder := New(http.MethodGet, testUrl, nil)
+
+	var firstOut Document
+	firstRaw, err := builder.unmarshalResponse(context.Background(), newBenchResponse(first), &firstOut, true)
+	require.NoError(t, err)
+
+	// Decoding again reuses the pooled buffer, which mustn't show through in the earlier results
+	for i := 0; i < 10; i++ {
+		var secondOut Document
+		_, err = builder.unmarshalResponse(context.Background(), newBenchResponse(second), &secondOut, false)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("b", 4096), secondOut.Name)
+		assert.JSONEq(t, `{"id":2}`, string(secondOut.Extra))
+	}
+
+	assert.Equal(t, strings.Repeat("a", 2048), firstOut.Name)
+	assert.JSONEq(t, `{"id":1}`, string(firstOut.Extra))
+	assert.Equal(t, first, firstRaw)
+}
+
+func BenchmarkRequestBuilder_unmarshalResponse(b *testing.B) {
+	body, err := json.Marshal(BlobResponse{Name: "blob", Data: make([]byte, 32<<10)})
+	require.NoError(b, err)
+	builder := New(http.MethodGet, testUrl, nil)
+
+	b.Run("ReadAll", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var out BlobResponse
+			data, err := ioutil.ReadAll(newBenchResponse(body).Body)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if err := json.Unmarshal(data, &out); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			var out BlobResponse
+			if _, err := builder.unmarshalResponse(context.Background(), newBenchResponse(body), &out, false); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,173 @@
+package httprequest
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache is a Doer that keeps successful GET responses in memory and replays them for identical requests until they
+// expire, as determined by the response's Cache-Control max-age or Expires header. Responses marked no-store, no-cache
+// or private, responses that vary on *, and responses without freshness information are never cached. Requests are
+// considered identical when their method, URL, and the values of any headers named by the response's Vary header match.
+type Cache struct {
+	doer Doer
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+type cacheEntry struct {
+	vary       http.Header
+	expires    time.Time
+	statusCode int
+	header     http.Header
+	body       []byte
+}
+
+func NewCache(doer Doer) *Cache {
+	return &Cache{
+		doer:    doer,
+		now:     time.Now,
+		entries: map[string]*cacheEntry{},
+	}
+}
+
+func (c *Cache) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return c.doer.Do(req)
+	}
+
+	key := req.Method + " " + req.URL.String()
+	if resp := c.lookup(key, req); resp != nil {
+		return resp, nil
+	}
+
+	resp, err := c.doer.Do(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+
+	expires, ok := cacheExpiry(resp.Header, c.now())
+	if !ok {
+		return resp, nil
+	}
+	vary := varyHeaders(resp.Header)
+	for _, name := range vary {
+		// A response that varies on everything can't match any later request
+		if name == "*" {
+			return resp, nil
+		}
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	entry := &cacheEntry{
+		vary:       http.Header{},
+		expires:    expires,
+		statusCode: resp.StatusCode,
+		header:     resp.Header.Clone(),
+		body:       body,
+	}
+	for _, name := range vary {
+		entry.vary[name] = req.Header.Values(name)
+	}
+
+	c.mu.Lock()
+	c.entries[key] = entry
+	c.mu.Unlock()
+
+	return resp, nil
+}
+
+func (c *Cache) lookup(key string, req *http.Request) *http.Response {
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	if ok && !c.now().Before(entry.expires) {
+		delete(c.entries, key)
+		ok = false
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		return nil
+	}
+
+	for name, vals := range entry.vary {
+		if strings.Join(vals, ",") != strings.Join(req.Header.Values(name), ",") {
+			return nil
+		}
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(entry.statusCode),
+		StatusCode:    entry.statusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        entry.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(entry.body)),
+		ContentLength: int64(len(entry.body)),
+		Request:       req,
+	}
+}
+
+// cacheExpiry determines when a response stops being fresh. Cache-Control takes precedence over Expires.
+func cacheExpiry(header http.Header, now time.Time) (time.Time, bool) {
+	// Directives may come in any order, so all of them are checked before max-age is trusted
+	maxAge := -1
+	for _, val := range header.Values(HeaderCacheControl) {
+		for _, directive := range strings.Split(val, ",") {
+			directive = strings.ToLower(strings.TrimSpace(directive))
+			switch {
+			case directive == "no-store", directive == "no-cache", directive == "private":
+				return time.Time{}, false
+			case strings.HasPrefix(directive, "max-age=") && maxAge < 0:
+				seconds, err := strconv.Atoi(strings.TrimPrefix(directive, "max-age="))
+				if err != nil {
+					seconds = 0
+				}
+				maxAge = seconds
+			}
+		}
+	}
+	if maxAge == 0 {
+		return time.Time{}, false
+	}
+	if maxAge > 0 {
+		return now.Add(time.Duration(maxAge) * time.Second), true
+	}
+
+	if expires := header.Get(HeaderExpires); expires != "" {
+		t, err := http.ParseTime(expires)
+		if err != nil || !t.After(now) {
+			return time.Time{}, false
+		}
+		return t, true
+	}
+
+	return time.Time{}, false
+}
+
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, val := range header.Values(HeaderVary) {
+		for _, name := range strings.Split(val, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
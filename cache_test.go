@@ -0,0 +1,116 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type countingDoer struct {
+	calls  int
+	header http.Header
+}
+
+func (d *countingDoer) Do(req *http.Request) (*http.Response, error) {
+	d.calls++
+	data, err := json.Marshal(resp1)
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     d.header.Clone(),
+		Body:       io.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func TestCache(t *testing.T) {
+	get := func(t *testing.T, doer Doer, accept string) {
+		var out UserResponse
+		builder := New(http.MethodGet, testUrl, nil)
+		if accept != "" {
+			builder.Accept(accept)
+		}
+		_, err := builder.Do(context.Background(), doer, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	}
+
+	t.Run("Identical GET within the max-age is served from the cache", func(t *testing.T) {
+		backend := &countingDoer{header: http.Header{"Cache-Control": {"public, max-age=60"}}}
+		cache := NewCache(backend)
+
+		get(t, cache, "")
+		get(t, cache, "")
+		assert.Equal(t, 1, backend.calls)
+	})
+	t.Run("Expired entries hit the backend again", func(t *testing.T) {
+		backend := &countingDoer{header: http.Header{"Cache-Control": {"max-age=60"}}}
+		cache := NewCache(backend)
+		now := time.Now()
+		cache.now = func() time.Time { return now }
+
+		get(t, cache, "")
+		now = now.Add(time.Minute)
+		get(t, cache, "")
+		assert.Equal(t, 2, backend.calls)
+	})
+	t.Run("Expires header is honored", func(t *testing.T) {
+		expires := time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)
+		backend := &countingDoer{header: http.Header{"Expires": {expires}}}
+		cache := NewCache(backend)
+
+		get(t, cache, "")
+		get(t, cache, "")
+		assert.Equal(t, 1, backend.calls)
+	})
+	t.Run("No-store responses are not cached", func(t *testing.T) {
+		backend := &countingDoer{header: http.Header{"Cache-Control": {"no-store"}}}
+		cache := NewCache(backend)
+
+		get(t, cache, "")
+		get(t, cache, "")
+		assert.Equal(t, 2, backend.calls)
+	})
+	t.Run("Private and no-cache after max-age are not cached", func(t *testing.T) {
+		for _, cacheControl := range []string{"max-age=60, private", "max-age=60, no-cache"} {
+			backend := &countingDoer{header: http.Header{"Cache-Control": {cacheControl}}}
+			cache := NewCache(backend)
+
+			get(t, cache, "")
+			get(t, cache, "")
+			assert.Equal(t, 2, backend.calls, cacheControl)
+		}
+	})
+	t.Run("Responses varying on everything are not cached", func(t *testing.T) {
+		backend := &countingDoer{header: http.Header{
+			"Cache-Control": {"max-age=60"},
+			"Vary":          {"*"},
+		}}
+		cache := NewCache(backend)
+
+		get(t, cache, "")
+		get(t, cache, "")
+		assert.Equal(t, 2, backend.calls)
+	})
+	t.Run("Varying headers are part of the cache key", func(t *testing.T) {
+		backend := &countingDoer{header: http.Header{
+			"Cache-Control": {"max-age=60"},
+			"Vary":          {"Accept"},
+		}}
+		cache := NewCache(backend)
+
+		get(t, cache, MIMEApplicationJson)
+		get(t, cache, MIMEApplicationJson)
+		get(t, cache, "application/*")
+		assert.Equal(t, 2, backend.calls)
+	})
+}
@@ -0,0 +1,215 @@
+package httprequest
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// UnixSocketURL is the conventional base URL for requests sent through DefaultUnixClient. The host is a placeholder
+// since the client always dials the socket, e.g. New(http.MethodGet, UnixSocketURL+"/v1/info", nil).
+const UnixSocketURL = "http://unix"
+
+// DefaultUnixClient returns a Doer whose transport dials the Unix domain socket at socketPath for every request.
+func DefaultUnixClient(socketPath string) Doer {
+	var dialer net.Dialer
+	return &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socketPath)
+			},
+		},
+	}
+}
+
+// DefaultIsolatedClient returns a Doer with its own transport, and so its own connection pool, configured like
+// http.DefaultTransport. Use it for endpoints whose slowness shouldn't starve connections shared with other traffic.
+func DefaultIsolatedClient() Doer {
+	return &http.Client{
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+	}
+}
+
+// transportConfig holds the builder options that only apply to the default Doer, which is used when Do is called
+// with a nil Doer.
+type transportConfig struct {
+	insecureSkipVerify bool
+	forceHTTP2         bool
+	minTLSVersion      uint16
+	dialTimeout        time.Duration
+	headerTimeout      time.Duration
+	expectContinue     bool
+	cookieJar          http.CookieJar
+}
+
+// strict reports whether the config holds options that must not be silently dropped, so sending them with an
+// explicitly provided Doer fails instead.
+func (c transportConfig) strict() bool {
+	return c.minTLSVersion != 0 || c.dialTimeout != 0 || c.headerTimeout != 0
+}
+
+// InsecureSkipVerify disables TLS certificate verification on the default Doer used when Do is called with a nil
+// Doer. This makes the connection vulnerable to man-in-the-middle attacks and should only be used for testing or for
+// internal services with self-signed certificates. It has no effect on an explicitly provided Doer.
+func (b *RequestBuilder) InsecureSkipVerify() *RequestBuilder {
+	b.transport.insecureSkipVerify = true
+	b.defaultClient = nil
+	return b
+}
+
+// MinTLSVersion sets the minimum TLS version, such as tls.VersionTLS12, accepted by the default Doer used when Do is
+// called with a nil Doer. Since the guarantee can't be applied to an explicitly provided Doer, sending with one fails.
+func (b *RequestBuilder) MinTLSVersion(v uint16) *RequestBuilder {
+	b.transport.minTLSVersion = v
+	b.defaultClient = nil
+	return b
+}
+
+// DialTimeout limits how long the default Doer used when Do is called with a nil Doer waits to establish a connection,
+// independently of the request's deadline. Sending with an explicitly provided Doer fails.
+func (b *RequestBuilder) DialTimeout(d time.Duration) *RequestBuilder {
+	b.transport.dialTimeout = d
+	b.defaultClient = nil
+	return b
+}
+
+// ResponseHeaderTimeout limits how long the default Doer used when Do is called with a nil Doer waits for the
+// response headers once the request has been written, independently of the request's deadline. Sending with an
+// explicitly provided Doer fails.
+func (b *RequestBuilder) ResponseHeaderTimeout(d time.Duration) *RequestBuilder {
+	b.transport.headerTimeout = d
+	b.defaultClient = nil
+	return b
+}
+
+// ForceHTTP2 makes the default Doer used when Do is called with a nil Doer attempt HTTP/2 over TLS even when other
+// options, such as InsecureSkipVerify or MinTLSVersion, customize its TLS configuration. Without it, those options
+// limit the default Doer to HTTP/1.1. It has no effect on an explicitly provided Doer.
+func (b *RequestBuilder) ForceHTTP2() *RequestBuilder {
+	b.transport.forceHTTP2 = true
+	b.defaultClient = nil
+	return b
+}
+
+// defaultExpectContinueTimeout is how long the default Doer waits for a 100 Continue before sending the body anyway.
+const defaultExpectContinueTimeout = time.Second
+
+// Expect100Continue sends an Expect: 100-continue header so the server can reject a large upload, for example for
+// failing authentication, before the body is sent. The default Doer used when Do is called with a nil Doer waits up
+// to a second for the server's go-ahead. An explicitly provided Doer only waits if its transport sets
+// ExpectContinueTimeout.
+func (b *RequestBuilder) Expect100Continue() *RequestBuilder {
+	b.transport.expectContinue = true
+	b.defaultClient = nil
+	return b.SetHeader(HeaderExpect, "100-continue")
+}
+
+// CookieJar makes the default Doer used when Do is called with a nil Doer store response cookies in jar and send
+// matching cookies with the request. Sharing a jar between builders carries cookies, such as a login session, from
+// one request to the next. It has no effect on an explicitly provided Doer.
+func (b *RequestBuilder) CookieJar(jar http.CookieJar) *RequestBuilder {
+	b.transport.cookieJar = jar
+	b.defaultClient = nil
+	return b
+}
+
+// defaultDoer returns the Doer used when none is provided. Builders without transport options share
+// http.DefaultClient, otherwise a client is created for the builder and reused across calls.
+func (b *RequestBuilder) defaultDoer() Doer {
+	if b.transport == (transportConfig{}) {
+		return http.DefaultClient
+	}
+
+	if b.defaultClient == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		if b.transport.insecureSkipVerify || b.transport.minTLSVersion != 0 {
+			if transport.TLSClientConfig == nil {
+				transport.TLSClientConfig = &tls.Config{}
+			}
+			transport.TLSClientConfig.InsecureSkipVerify = b.transport.insecureSkipVerify
+			transport.TLSClientConfig.MinVersion = b.transport.minTLSVersion
+			// Like net/http with a custom TLS config, only HTTP/1.1 is negotiated unless HTTP/2 is asked for. The
+			// protocols already advertised by the cloned config are cleared so the transport sets them again.
+			transport.TLSClientConfig.NextProtos = nil
+			transport.ForceAttemptHTTP2 = b.transport.forceHTTP2
+		}
+		if b.transport.dialTimeout != 0 {
+			dialer := &net.Dialer{Timeout: b.transport.dialTimeout, KeepAlive: 30 * time.Second}
+			transport.DialContext = dialer.DialContext
+		}
+		if b.transport.headerTimeout != 0 {
+			transport.ResponseHeaderTimeout = b.transport.headerTimeout
+		}
+		if b.transport.expectContinue {
+			transport.ExpectContinueTimeout = defaultExpectContinueTimeout
+		}
+		b.defaultClient = &http.Client{Transport: transport, Jar: b.transport.cookieJar}
+	}
+
+	return b.defaultClient
+}
+
+// ClientBuilder configures a Doer backed by its own http.Client and transport, so transport concerns like timeouts,
+// connection pooling, TLS, and redirects can be set up without constructing an http.Client by hand.
+type ClientBuilder struct {
+	timeout       time.Duration
+	maxIdleConns  int
+	tlsConfig     *tls.Config
+	checkRedirect func(req *http.Request, via []*http.Request) error
+}
+
+// NewClientBuilder returns a ClientBuilder starting from the settings of http.DefaultTransport.
+func NewClientBuilder() *ClientBuilder {
+	return &ClientBuilder{}
+}
+
+// Timeout limits the total time of each request, including reading the response body. Zero means no timeout.
+func (c *ClientBuilder) Timeout(d time.Duration) *ClientBuilder {
+	c.timeout = d
+	return c
+}
+
+// MaxIdleConns sets the maximum number of idle connections kept across all hosts, and per host.
+func (c *ClientBuilder) MaxIdleConns(n int) *ClientBuilder {
+	c.maxIdleConns = n
+	return c
+}
+
+// TLSConfig sets the TLS configuration used for HTTPS connections.
+func (c *ClientBuilder) TLSConfig(config *tls.Config) *ClientBuilder {
+	c.tlsConfig = config
+	return c
+}
+
+// CheckRedirect sets the redirect policy, with the same semantics as http.Client.CheckRedirect.
+func (c *ClientBuilder) CheckRedirect(fn func(req *http.Request, via []*http.Request) error) *ClientBuilder {
+	c.checkRedirect = fn
+	return c
+}
+
+// NoRedirects makes the client return redirect responses as-is instead of following them.
+func (c *ClientBuilder) NoRedirects() *ClientBuilder {
+	return c.CheckRedirect(func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	})
+}
+
+// Build returns a new Doer with the configured settings. Each call creates a separate connection pool.
+func (c *ClientBuilder) Build() Doer {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.maxIdleConns > 0 {
+		transport.MaxIdleConns = c.maxIdleConns
+		transport.MaxIdleConnsPerHost = c.maxIdleConns
+	}
+	if c.tlsConfig != nil {
+		transport.TLSClientConfig = c.tlsConfig.Clone()
+	}
+
+	return &http.Client{
+		Transport:     transport,
+		Timeout:       c.timeout,
+		CheckRedirect: c.checkRedirect,
+	}
+}
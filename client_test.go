@@ -0,0 +1,320 @@
+package httprequest
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefaultUnixClient(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "test.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/users/42", r.URL.Path)
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	var out UserResponse
+	resp, err := New(http.MethodGet, UnixSocketURL+"/v1/users/42", nil).
+		Do(context.Background(), DefaultUnixClient(socketPath), &out)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, resp1, out)
+}
+
+func TestDefaultIsolatedClient(t *testing.T) {
+	first := DefaultIsolatedClient().(*http.Client)
+	second := DefaultIsolatedClient().(*http.Client)
+
+	require.NotNil(t, first.Transport)
+	require.NotNil(t, second.Transport)
+	assert.NotSame(t, first.Transport, second.Transport)
+	assert.NotSame(t, http.DefaultTransport, first.Transport)
+}
+
+func TestRequestBuilder_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	defer server.Close()
+
+	t.Run("Self-signed certificate is rejected by default", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).Do(context.Background(), nil, &out)
+		require.Error(t, err)
+	})
+	t.Run("Self-signed certificate is accepted with the option", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			InsecureSkipVerify().
+			Do(context.Background(), nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	})
+}
+
+func TestRequestBuilder_MinTLSVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.StartTLS()
+	defer server.Close()
+
+	t.Run("Server below the minimum version is rejected", func(t *testing.T) {
+		_, err := New(http.MethodGet, server.URL, nil).
+			InsecureSkipVerify().
+			MinTLSVersion(tls.VersionTLS13).
+			Do(context.Background(), nil, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "protocol version")
+	})
+	t.Run("Server at the minimum version is accepted", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			InsecureSkipVerify().
+			MinTLSVersion(tls.VersionTLS12).
+			Do(context.Background(), nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	})
+	t.Run("Explicit Doer returns an error", func(t *testing.T) {
+		_, err := New(http.MethodGet, server.URL, nil).
+			MinTLSVersion(tls.VersionTLS12).
+			Do(context.Background(), server.Client(), nil)
+		require.Error(t, err)
+	})
+}
+
+func TestRequestBuilder_DialTimeout(t *testing.T) {
+	// Connections to a non-routable address are never answered, so only the dial timeout can end them
+	const unroutable = "10.255.255.1:81"
+	conn, err := net.DialTimeout("tcp", unroutable, 50*time.Millisecond)
+	if err == nil {
+		_ = conn.Close()
+	}
+	var probeErr net.Error
+	if !errors.As(err, &probeErr) || !probeErr.Timeout() {
+		t.Skipf("dialing %s didn't time out (%v), so this network can't simulate an unanswered dial", unroutable, err)
+	}
+
+	start := time.Now()
+	_, err = New(http.MethodGet, "http://"+unroutable, nil).
+		DialTimeout(50*time.Millisecond).
+		Do(context.Background(), nil, nil)
+	var netErr net.Error
+	require.True(t, errors.As(err, &netErr), "expected a net.Error, got %v", err)
+	assert.True(t, netErr.Timeout(), "expected a timeout, got %v", err)
+	assert.Less(t, time.Since(start), 2*time.Second)
+}
+
+func TestRequestBuilder_ResponseHeaderTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	t.Run("Slow headers time out", func(t *testing.T) {
+		start := time.Now()
+		_, err := New(http.MethodGet, server.URL, nil).
+			ResponseHeaderTimeout(50*time.Millisecond).
+			DialTimeout(time.Second).
+			Do(context.Background(), nil, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "timeout awaiting response headers")
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+	t.Run("Explicit Doer returns an error", func(t *testing.T) {
+		_, err := New(http.MethodGet, server.URL, nil).
+			ResponseHeaderTimeout(50*time.Millisecond).
+			Do(context.Background(), server.Client(), nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "only apply to the default Doer")
+	})
+}
+
+func TestRequestBuilder_ForceHTTP2(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	server.EnableHTTP2 = true
+	// Offer both protocols so the client's choice decides which one is used
+	server.TLS = &tls.Config{NextProtos: []string{"h2", "http/1.1"}}
+	server.StartTLS()
+	defer server.Close()
+
+	t.Run("Customized TLS negotiates HTTP/2", func(t *testing.T) {
+		var out UserResponse
+		resp, err := New(http.MethodGet, server.URL, nil).
+			InsecureSkipVerify().
+			ForceHTTP2().
+			Do(context.Background(), nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/2.0", resp.Proto)
+		assert.Equal(t, resp1, out)
+	})
+	t.Run("Customized TLS without it negotiates HTTP/1.1", func(t *testing.T) {
+		var out UserResponse
+		resp, err := New(http.MethodGet, server.URL, nil).
+			InsecureSkipVerify().
+			Do(context.Background(), nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, "HTTP/1.1", resp.Proto)
+		assert.Equal(t, resp1, out)
+	})
+}
+
+func TestRequestBuilder_Expect100Continue(t *testing.T) {
+	var bytesSent int64
+	onProgress := func(sent, _ int64) {
+		atomic.StoreInt64(&bytesSent, sent)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "100-continue", r.Header.Get(HeaderExpect))
+		if r.Header.Get(HeaderAuthorization) == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// The server only sends 100 Continue once the handler reads the body, so none of it can have been sent yet
+		assert.Zero(t, atomic.LoadInt64(&bytesSent))
+		var in UserRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, req1, in)
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	defer server.Close()
+
+	t.Run("Body is sent after 100 Continue", func(t *testing.T) {
+		atomic.StoreInt64(&bytesSent, 0)
+
+		var out UserResponse
+		_, err := New(http.MethodPut, server.URL, req1).
+			SetHeader(HeaderAuthorization, "Bearer token").
+			Expect100Continue().
+			OnUploadProgress(onProgress).
+			Do(context.Background(), nil, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Positive(t, atomic.LoadInt64(&bytesSent))
+	})
+	t.Run("Rejected request never sends the body", func(t *testing.T) {
+		atomic.StoreInt64(&bytesSent, 0)
+
+		_, err := New(http.MethodPut, server.URL, req1).
+			Expect100Continue().
+			OnUploadProgress(onProgress).
+			Do(context.Background(), nil, nil)
+		assert.True(t, errors.Is(err, ErrUnauthorized))
+		assert.Zero(t, atomic.LoadInt64(&bytesSent))
+	})
+}
+
+func TestRequestBuilder_CookieJar(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc123", Path: "/"})
+			w.WriteHeader(http.StatusNoContent)
+		case "/me":
+			cookie, err := r.Cookie("session")
+			if err != nil || cookie.Value != "abc123" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set(HeaderContentType, MIMEApplicationJson)
+			_ = json.NewEncoder(w).Encode(resp1)
+		}
+	}))
+	defer server.Close()
+
+	jar, err := cookiejar.New(nil)
+	require.NoError(t, err)
+
+	_, err = New(http.MethodPost, server.URL+"/login", nil).
+		CookieJar(jar).
+		StatusIs(http.StatusNoContent).
+		ExpectEmptyBody().
+		Do(context.Background(), nil, nil)
+	require.NoError(t, err)
+
+	var out UserResponse
+	_, err = Get(server.URL+"/me").CookieJar(jar).Do(context.Background(), nil, &out)
+	require.NoError(t, err)
+	assert.Equal(t, resp1, out)
+
+	_, err = Get(server.URL+"/me").Do(context.Background(), nil, &out)
+	assert.True(t, errors.Is(err, ErrUnauthorized))
+}
+
+func TestClientBuilder(t *testing.T) {
+	t.Run("Timeout is enforced", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		defer server.Close()
+		defer close(release)
+
+		doer := NewClientBuilder().Timeout(50 * time.Millisecond).Build()
+		start := time.Now()
+		_, err := Get(server.URL).Do(context.Background(), doer, nil)
+		require.Error(t, err)
+		var netErr net.Error
+		require.True(t, errors.As(err, &netErr), "expected a net.Error, got %v", err)
+		assert.True(t, netErr.Timeout())
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+	t.Run("Transport settings are applied", func(t *testing.T) {
+		tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+		client := NewClientBuilder().MaxIdleConns(5).TLSConfig(tlsConfig).Build().(*http.Client)
+
+		transport := client.Transport.(*http.Transport)
+		assert.Equal(t, 5, transport.MaxIdleConns)
+		assert.Equal(t, 5, transport.MaxIdleConnsPerHost)
+		assert.Equal(t, uint16(tls.VersionTLS12), transport.TLSClientConfig.MinVersion)
+	})
+	t.Run("NoRedirects returns the redirect response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderLocation, "/elsewhere")
+			w.WriteHeader(http.StatusFound)
+		}))
+		defer server.Close()
+
+		resp, err := Get(server.URL).StatusIs(http.StatusFound).DoStream(context.Background(), NewClientBuilder().NoRedirects().Build())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, "/elsewhere", resp.Header.Get(HeaderLocation))
+	})
+}
@@ -0,0 +1,73 @@
+package httprequest
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+)
+
+const redactedValue = "REDACTED"
+
+type curlConfig struct {
+	redactAuthorization bool
+}
+
+type CurlOption func(*curlConfig)
+
+// CurlRedactAuthorization replaces the Authorization header value in the exported command so it can be shared safely.
+func CurlRedactAuthorization() CurlOption {
+	return func(c *curlConfig) {
+		c.redactAuthorization = true
+	}
+}
+
+// ToCurl builds the request and renders it as an equivalent curl command.
+func (b *RequestBuilder) ToCurl(ctx context.Context, opts ...CurlOption) (string, error) {
+	var cfg curlConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	req, err := b.Build(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	parts := []string{"curl", "-X", req.Method, shellQuote(req.URL.String())}
+
+	keys := make([]string, 0, len(req.Header))
+	for key := range req.Header {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		for _, val := range req.Header[key] {
+			if cfg.redactAuthorization && key == HeaderAuthorization {
+				val = redactedValue
+			}
+			parts = append(parts, "-H", shellQuote(fmt.Sprintf("%s: %s", key, val)))
+		}
+	}
+
+	if req.Body != nil {
+		bodyBytes, err := ioutil.ReadAll(req.Body)
+		// The request is never sent, so nothing else closes the body, which may be a file opened by BodyFromFile
+		_ = req.Body.Close()
+		if err != nil {
+			return "", fmt.Errorf("unable to read request body: %v", err)
+		}
+		if len(bodyBytes) > 0 {
+			parts = append(parts, "--data", shellQuote(string(bodyBytes)))
+		}
+	}
+
+	return strings.Join(parts, " "), nil
+}
+
+// shellQuote wraps s in single quotes, escaping any single quotes it contains.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,58 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_ToCurl(t *testing.T) {
+	t.Run("Curl command contains method, url, headers, and data", func(t *testing.T) {
+		cmd, err := New(http.MethodPost, testUrl, req1).
+			SetHeader(HeaderAuthorization, "Bearer secret").
+			ToCurl(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, cmd, "curl -X POST")
+		assert.Contains(t, cmd, "'"+testUrl+"'")
+		assert.Contains(t, cmd, "-H 'Authorization: Bearer secret'")
+		assert.Contains(t, cmd, "-H 'Content-Type: application/json'")
+		assert.Contains(t, cmd, `--data '{"id":6,"name":"jack","isAdmin":true}'`)
+	})
+	t.Run("Authorization header can be redacted", func(t *testing.T) {
+		cmd, err := New(http.MethodGet, testUrl, nil).
+			SetHeader(HeaderAuthorization, "Bearer secret").
+			ToCurl(context.Background(), CurlRedactAuthorization())
+		require.NoError(t, err)
+		assert.Contains(t, cmd, "-H 'Authorization: REDACTED'")
+		assert.NotContains(t, cmd, "secret")
+		assert.NotContains(t, cmd, "--data")
+	})
+	t.Run("Single quotes in the body are escaped", func(t *testing.T) {
+		cmd, err := New(http.MethodPost, testUrl, UserRequest{Name: "o'brien"}).
+			ToCurl(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, cmd, `"name":"o'\''brien"`)
+	})
+	t.Run("File body is closed after it's read", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "user.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"id":6}`), 0o600))
+
+		var file *os.File
+		cmd, err := New(http.MethodPut, testUrl, nil).
+			BodyFromFile(path).
+			OnRequest(func(req *http.Request) error {
+				file, _ = req.Body.(*os.File)
+				return nil
+			}).
+			ToCurl(context.Background())
+		require.NoError(t, err)
+		assert.Contains(t, cmd, `--data '{"id":6}'`)
+		require.NotNil(t, file, "expected the request body to be the opened file")
+		assert.ErrorIs(t, file.Close(), os.ErrClosed)
+	})
+}
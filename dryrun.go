@@ -0,0 +1,38 @@
+package httprequest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// DryRun makes the builder write the request it would send to w instead of sending it. Do returns a synthetic,
+// empty 200 response without invoking the Doer and without validating or decoding it.
+func (b *RequestBuilder) DryRun(w io.Writer) *RequestBuilder {
+	b.dryRun = w
+	return b
+}
+
+func (b *RequestBuilder) sendDryRun(ctx context.Context) (*http.Response, error) {
+	req, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = req.Write(b.dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("unable to write dry run request: %v", err)
+	}
+
+	return &http.Response{
+		Status:     http.StatusText(http.StatusOK),
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{},
+		Body:       http.NoBody,
+		Request:    req,
+	}, nil
+}
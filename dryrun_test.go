@@ -0,0 +1,56 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_DryRun(t *testing.T) {
+	var called bool
+	doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return nil, nil
+	})
+
+	var buf bytes.Buffer
+	var out UserResponse
+	resp, err := New(http.MethodPost, testUrl, req1).
+		StatusIs(http.StatusCreated).
+		DryRun(&buf).
+		Do(context.Background(), doer, &out)
+	require.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, out)
+
+	description := buf.String()
+	assert.Contains(t, description, "POST /api/v1/endpoint HTTP/1.1")
+	assert.Contains(t, description, "Host: example.com")
+	assert.Contains(t, description, "Content-Type: application/json")
+	assert.Contains(t, description, `{"id":6,"name":"jack","isAdmin":true}`)
+}
+
+func TestRequestBuilder_DryRun_DoSwitch(t *testing.T) {
+	var called bool
+	doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+		called = true
+		return nil, nil
+	})
+
+	var buf bytes.Buffer
+	var out UserResponse
+	status, resp, err := New(http.MethodGet, testUrl, nil).
+		DryRun(&buf).
+		DoSwitch(context.Background(), doer, map[int]interface{}{http.StatusOK: &out})
+	require.NoError(t, err)
+	assert.False(t, called)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, out)
+	assert.Contains(t, buf.String(), "GET /api/v1/endpoint HTTP/1.1")
+}
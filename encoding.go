@@ -0,0 +1,126 @@
+package httprequest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// Encoding compresses the request body with the given content coding, either EncodingGzip or EncodingDeflate, and
+// sets the Content-Encoding header to match.
+func (b *RequestBuilder) Encoding(enc string) *RequestBuilder {
+	if enc != EncodingGzip && enc != EncodingDeflate {
+		b.err = fmt.Errorf("unsupported content encoding: %s", enc)
+		return b
+	}
+
+	b.contentEncoding = enc
+	b.invalidateBody()
+	return b
+}
+
+// AcceptGzip asks the server for a gzip-compressed response by setting Accept-Encoding: gzip. Setting the header
+// turns off the transport's own transparent decompression, so the builder decompresses the response itself: Do
+// decodes the decompressed body, and DoStream hands out a body that decompresses as it's read.
+func (b *RequestBuilder) AcceptGzip() *RequestBuilder {
+	b.acceptGzip = true
+	return b.SetHeader(HeaderAcceptEncoding, EncodingGzip)
+}
+
+// gzipReadCloser decompresses a response body while reading it, closing the underlying body on Close.
+type gzipReadCloser struct {
+	*gzip.Reader
+	body io.ReadCloser
+}
+
+func (r *gzipReadCloser) Close() error {
+	_ = r.Reader.Close()
+	return r.body.Close()
+}
+
+// decompressStream makes a gzip-encoded response body decompress as it's read.
+func decompressStream(resp *http.Response) error {
+	if resp.Header.Get(HeaderContentEncoding) != EncodingGzip {
+		return nil
+	}
+
+	gr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return fmt.Errorf("unable to decompress gzip response body: %v", err)
+	}
+
+	resp.Body = &gzipReadCloser{Reader: gr, body: resp.Body}
+	resp.Header.Del(HeaderContentEncoding)
+	resp.Header.Del(HeaderContentLength)
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return nil
+}
+
+func compressBody(enc string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch enc {
+	case EncodingGzip:
+		w = gzip.NewWriter(&buf)
+	case EncodingDeflate:
+		w = zlib.NewWriter(&buf)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", enc)
+	}
+
+	_, err := w.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress body: %v", err)
+	}
+	err = w.Close()
+	if err != nil {
+		return nil, fmt.Errorf("unable to compress body: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// decompressBody reverses the Content-Encoding of a response body. Bodies without an encoding are returned as-is. A
+// positive limit caps the size of the decompressed body, so a small compressed body can't expand without bound.
+func decompressBody(enc string, data []byte, limit int64) ([]byte, error) {
+	var r io.ReadCloser
+	var err error
+	switch enc {
+	case "", "identity":
+		return data, nil
+	case EncodingGzip:
+		r, err = gzip.NewReader(bytes.NewReader(data))
+	case EncodingDeflate:
+		r, err = zlib.NewReader(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("unsupported response content encoding: %s", enc)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress %s response body: %v", enc, err)
+	}
+	defer r.Close()
+
+	var decompressed io.Reader = r
+	if limit > 0 {
+		decompressed = io.LimitReader(r, limit+1)
+	}
+	data, err = ioutil.ReadAll(decompressed)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress %s response body: %v", enc, err)
+	}
+
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, &SizeLimitError{Side: "response", Limit: limit, Observed: int64(len(data))}
+	}
+	return data, nil
+}
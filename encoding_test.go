@@ -0,0 +1,120 @@
+package httprequest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_Encoding(t *testing.T) {
+	t.Run("Deflate body round trips", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, EncodingDeflate, r.Header.Get(HeaderContentEncoding))
+			zr, err := zlib.NewReader(r.Body)
+			require.NoError(t, err)
+			var in UserRequest
+			require.NoError(t, json.NewDecoder(zr).Decode(&in))
+			assert.Equal(t, req1, in)
+
+			w.Header().Set(HeaderContentType, MIMEApplicationJson)
+			w.Header().Set(HeaderContentEncoding, EncodingDeflate)
+			zw := zlib.NewWriter(w)
+			_ = json.NewEncoder(zw).Encode(resp1)
+			_ = zw.Close()
+		}))
+		defer server.Close()
+
+		var out UserResponse
+		_, err := New(http.MethodPost, server.URL, req1).
+			Encoding(EncodingDeflate).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	})
+	t.Run("Gzip body is compressed", func(t *testing.T) {
+		req, err := New(http.MethodPost, testUrl, req1).
+			Encoding(EncodingGzip).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, EncodingGzip, req.Header.Get(HeaderContentEncoding))
+
+		expectedBytes, err := json.Marshal(req1)
+		require.NoError(t, err)
+		compressed, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		bodyBytes, err := decompressBody(EncodingGzip, compressed, 0)
+		require.NoError(t, err)
+		assert.Equal(t, expectedBytes, bodyBytes)
+	})
+	t.Run("Unsupported encoding returns an error from Build", func(t *testing.T) {
+		_, err := New(http.MethodPost, testUrl, req1).
+			Encoding("compress").
+			Build(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestRequestBuilder_Do_gzippedResponse(t *testing.T) {
+	mock := httpmock.NewMock()
+	mock.GET(testUrl).ReturnGzipped(http.StatusOK, resp1)
+
+	var out UserResponse
+	raw, _, err := New(http.MethodGet, testUrl, nil).DoWithRaw(context.Background(), mock, &out)
+	require.NoError(t, err)
+	assert.Equal(t, resp1, out)
+	assert.JSONEq(t, `{"id": 42, "name": "stephen", "isAdmin": false}`, string(raw))
+	mock.AssertExpectations(t)
+}
+
+func TestRequestBuilder_AcceptGzip(t *testing.T) {
+	var compressedBytes int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		if r.Header.Get(HeaderAcceptEncoding) != EncodingGzip {
+			_ = json.NewEncoder(w).Encode(resp1)
+			return
+		}
+
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		_ = json.NewEncoder(gw).Encode(resp1)
+		_ = gw.Close()
+		compressedBytes = buf.Len()
+		w.Header().Set(HeaderContentEncoding, EncodingGzip)
+		_, _ = w.Write(buf.Bytes())
+	}))
+	defer server.Close()
+
+	t.Run("Do decompresses the response", func(t *testing.T) {
+		var out UserResponse
+		resp, err := New(http.MethodGet, server.URL, nil).
+			AcceptGzip().
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Equal(t, EncodingGzip, resp.Header.Get(HeaderContentEncoding))
+		assert.Equal(t, int64(compressedBytes), resp.ContentLength)
+	})
+	t.Run("DoStream decompresses while reading", func(t *testing.T) {
+		resp, err := New(http.MethodGet, server.URL, nil).
+			AcceptGzip().
+			DoStream(context.Background(), server.Client())
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var out UserResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		assert.Equal(t, resp1, out)
+		assert.True(t, resp.Uncompressed)
+	})
+}
@@ -0,0 +1,86 @@
+package httprequest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+var (
+	ErrBadRequest         = errors.New("bad request")
+	ErrUnauthorized       = errors.New("unauthorized")
+	ErrForbidden          = errors.New("forbidden")
+	ErrNotFound           = errors.New("not found")
+	ErrConflict           = errors.New("conflict")
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrTooManyRequests    = errors.New("too many requests")
+	ErrInternalServer     = errors.New("internal server error")
+)
+
+var statusSentinels = map[int]error{
+	http.StatusBadRequest:          ErrBadRequest,
+	http.StatusUnauthorized:        ErrUnauthorized,
+	http.StatusForbidden:           ErrForbidden,
+	http.StatusNotFound:            ErrNotFound,
+	http.StatusConflict:            ErrConflict,
+	http.StatusPreconditionFailed:  ErrPreconditionFailed,
+	http.StatusTooManyRequests:     ErrTooManyRequests,
+	http.StatusInternalServerError: ErrInternalServer,
+}
+
+// maxErrorBodyBytes caps how much of an unexpected response's body is kept on its StatusError.
+const maxErrorBodyBytes = 4 << 10
+
+// StatusError is returned when a response has an unexpected status code. For common status codes it wraps the
+// matching sentinel error, so callers can check for them with errors.Is(err, ErrNotFound).
+type StatusError struct {
+	StatusCode int
+	// Body holds up to the first 4KiB of the response body as received, which may be HTML or binary rather than the
+	// expected format. It's never included in the error message.
+	Body []byte
+	// DecodeErr is the error decoding the body into the DecodeInto, ErrorStatusIn or DecodeErrors target, when that
+	// failed. The target is left partially decoded or empty.
+	DecodeErr error
+}
+
+func newStatusError(resp *http.Response) *StatusError {
+	return &StatusError{StatusCode: resp.StatusCode}
+}
+
+// newStatusErrorWithBody is like newStatusError, but keeps the start of the response body. The body isn't closed.
+func newStatusErrorWithBody(resp *http.Response) *StatusError {
+	statusErr := newStatusError(resp)
+	if resp.Body != nil {
+		// The body is only kept for diagnostics, so a failed read keeps whatever arrived before it
+		statusErr.Body, _ = ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	}
+	return statusErr
+}
+
+func (e *StatusError) Error() string {
+	if e.DecodeErr != nil {
+		return fmt.Sprintf("received unexpected status code: %v, and %v", e.StatusCode, e.DecodeErr)
+	}
+	return fmt.Sprintf("received unexpected status code: %v", e.StatusCode)
+}
+
+func (e *StatusError) Unwrap() error {
+	return statusSentinels[e.StatusCode]
+}
+
+// SizeLimitError is returned when a request or response body is larger than the limit configured with
+// WithRequestLimit or WithResponseLimit.
+type SizeLimitError struct {
+	// Side is either "request" or "response"
+	Side  string
+	Limit int64
+	// Observed is the size of the body. For a response without a Content-Length, reading stops just past the limit,
+	// so it's a lower bound.
+	Observed int64
+}
+
+func (e *SizeLimitError) Error() string {
+	return fmt.Sprintf("%s body of %d bytes exceeds the limit of %d bytes", e.Side, e.Observed, e.Limit)
+}
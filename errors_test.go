@@ -0,0 +1,131 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusError(t *testing.T) {
+	tests := []struct {
+		name       string
+		statusCode int
+		sentinel   error
+	}{
+		{
+			name:       "Not found wraps ErrNotFound",
+			statusCode: http.StatusNotFound,
+			sentinel:   ErrNotFound,
+		},
+		{
+			name:       "Too many requests wraps ErrTooManyRequests",
+			statusCode: http.StatusTooManyRequests,
+			sentinel:   ErrTooManyRequests,
+		},
+		{
+			name:       "Unknown status wraps no sentinel",
+			statusCode: http.StatusTeapot,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mock := httpmock.NewMock()
+			mock.GET(testUrl).Return(tt.statusCode, nil, nil)
+
+			_, err := New(http.MethodGet, testUrl, nil).Do(ctx, mock, nil)
+			require.Error(t, err)
+
+			var statusErr *StatusError
+			require.True(t, errors.As(err, &statusErr))
+			assert.Equal(t, tt.statusCode, statusErr.StatusCode)
+			if tt.sentinel != nil {
+				assert.True(t, errors.Is(err, tt.sentinel))
+			} else {
+				assert.Nil(t, errors.Unwrap(err))
+			}
+			assert.False(t, errors.Is(err, ErrConflict))
+			mock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRequestBuilder_IfMatch(t *testing.T) {
+	etag := `"v1"`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		switch {
+		case r.Method == http.MethodGet:
+			w.Header().Set(HeaderETag, etag)
+		case r.Header.Get(HeaderIfMatch) != etag:
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	defer server.Close()
+
+	var current UserResponse
+	resp, err := New(http.MethodGet, server.URL, nil).Do(context.Background(), server.Client(), &current)
+	require.NoError(t, err)
+	require.Equal(t, etag, ETag(resp))
+
+	t.Run("Matching ETag succeeds", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodPut, server.URL, current).
+			IfMatch(ETag(resp)).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+	})
+	t.Run("Stale ETag returns a precondition error", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodPut, server.URL, current).
+			IfMatch(`"v0"`).
+			Do(context.Background(), server.Client(), &out)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrPreconditionFailed))
+
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr))
+		assert.Equal(t, http.StatusPreconditionFailed, statusErr.StatusCode)
+	})
+}
+
+func TestStatusError_Body(t *testing.T) {
+	binaryBody := []byte{0xff, 0xfe, 0x00, 0x1b, '<', 'h', 't', 'm', 'l', '>', 0xc3, 0x28}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, "application/octet-stream")
+		w.WriteHeader(http.StatusInternalServerError)
+		if r.URL.Path == "/large" {
+			_, _ = w.Write(bytes.Repeat([]byte{0xff}, 3*maxErrorBodyBytes))
+			return
+		}
+		_, _ = w.Write(binaryBody)
+	}))
+	defer server.Close()
+
+	t.Run("Binary body is captured without leaking into the message", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).Do(context.Background(), server.Client(), &out)
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr), "expected a StatusError, got %v", err)
+		assert.Equal(t, "received unexpected status code: 500", err.Error())
+		assert.True(t, utf8.ValidString(err.Error()))
+		assert.Equal(t, binaryBody, statusErr.Body)
+		assert.True(t, errors.Is(err, ErrInternalServer))
+	})
+	t.Run("Captured body is capped", func(t *testing.T) {
+		_, err := New(http.MethodGet, server.URL+"/large", nil).Do(context.Background(), server.Client(), nil)
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr), "expected a StatusError, got %v", err)
+		assert.Len(t, statusErr.Body, maxErrorBodyBytes)
+	})
+}
@@ -0,0 +1,50 @@
+package httprequest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// RequireFields makes Do fail unless every dot-separated JSON path, such as "user.id", is present in the response
+// body with a non-zero value. It guards against servers answering with an empty or partial object and a 200.
+func (b *RequestBuilder) RequireFields(paths ...string) *RequestBuilder {
+	b.requiredFields = append(b.requiredFields, paths...)
+	return b
+}
+
+func (b *RequestBuilder) validateRequiredFields(contentType string, respBytes []byte) error {
+	if !isJSONContentType(contentType) {
+		return fmt.Errorf("required fields are only supported for json, got %s", contentType)
+	}
+
+	var missing []string
+	for _, path := range b.requiredFields {
+		data, err := extractJSONPath(respBytes, path)
+		if err != nil || isZeroJSON(data) {
+			missing = append(missing, path)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("response is missing required fields: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+func isZeroJSON(data []byte) bool {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return true
+	}
+
+	switch v := v.(type) {
+	case map[string]interface{}:
+		return len(v) == 0
+	case []interface{}:
+		return len(v) == 0
+	default:
+		return v == nil || v == reflect.Zero(reflect.TypeOf(v)).Interface()
+	}
+}
@@ -0,0 +1,43 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_RequireFields(t *testing.T) {
+	newServer := func(body string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderContentType, MIMEApplicationJson)
+			_, _ = w.Write([]byte(body))
+		}))
+	}
+
+	t.Run("Complete response passes", func(t *testing.T) {
+		server := newServer(`{"id": 42, "name": "stephen", "team": {"id": 7}}`)
+		defer server.Close()
+
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			RequireFields("id", "name", "team.id").
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, 42, out.ID)
+	})
+	t.Run("Missing and zero fields are reported", func(t *testing.T) {
+		server := newServer(`{"id": 42, "name": "", "team": {}}`)
+		defer server.Close()
+
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			RequireFields("id", "name", "team.id").
+			Do(context.Background(), server.Client(), &out)
+		require.Error(t, err)
+		assert.Equal(t, "response is missing required fields: name, team.id", err.Error())
+	})
+}
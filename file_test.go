@@ -0,0 +1,66 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_BodyFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.json")
+	contents := []byte(`{"id":6,"name":"jack"}`)
+	require.NoError(t, os.WriteFile(path, contents, 0o600))
+
+	t.Run("File is streamed with its length and detected content type", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, err := ioutil.ReadAll(r.Body)
+			require.NoError(t, err)
+			assert.Equal(t, contents, body)
+			assert.Equal(t, int64(len(contents)), r.ContentLength)
+			assert.Equal(t, MIMEApplicationJson, r.Header.Get(HeaderContentType))
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		_, err := New(http.MethodPut, server.URL, nil).
+			BodyFromFile(path).
+			StatusIs(http.StatusNoContent).
+			ExpectEmptyBody().
+			Do(context.Background(), server.Client(), nil)
+		require.NoError(t, err)
+	})
+	t.Run("Explicit content type overrides detection", func(t *testing.T) {
+		req, err := New(http.MethodPut, testUrl, nil).
+			ContentType("application/vnd.report+json").
+			BodyFromFile(path).
+			Build(context.Background())
+		require.NoError(t, err)
+		defer req.Body.Close()
+		assert.Equal(t, "application/vnd.report+json", req.Header.Get(HeaderContentType))
+	})
+	t.Run("Signing a file body returns an error from Build", func(t *testing.T) {
+		builder := New(http.MethodPut, testUrl, nil).
+			BodyFromFile(path).
+			SignRequest(func(req *http.Request, body []byte) error {
+				t.Error("signer shouldn't be called without the body bytes")
+				return nil
+			})
+		_, err := builder.Build(context.Background())
+		assert.True(t, errors.Is(err, errSignFileBody), "expected errSignFileBody, got %v", err)
+		assert.True(t, errors.Is(builder.Validate(), errSignFileBody))
+	})
+	t.Run("Missing file returns an error from Build", func(t *testing.T) {
+		_, err := New(http.MethodPut, testUrl, nil).
+			BodyFromFile(filepath.Join(t.TempDir(), "missing.json")).
+			Build(context.Background())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,106 @@
+package httprequest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// WithHedging sends a second copy of the request to backup if the primary doer hasn't responded within delay. The
+// first successful response wins and the other request's context is cancelled. Only use it for idempotent requests.
+func (b *RequestBuilder) WithHedging(delay time.Duration, backup Doer) *RequestBuilder {
+	b.hedgeDelay = delay
+	b.hedgeBackup = backup
+	return b
+}
+
+type hedgeResult struct {
+	attempt int
+	resp    *http.Response
+	err     error
+}
+
+func (b *RequestBuilder) sendHedged(ctx context.Context, primary Doer) (*http.Response, error) {
+	results := make(chan hedgeResult, 2)
+	var cancels []context.CancelFunc
+	launch := func(doer Doer) error {
+		attemptCtx, cancel := context.WithCancel(ctx)
+		req, err := b.Build(attemptCtx)
+		if err != nil {
+			cancel()
+			return err
+		}
+
+		attempt := len(cancels)
+		cancels = append(cancels, cancel)
+		go func() {
+			resp, err := doer.Do(req)
+			results <- hedgeResult{attempt: attempt, resp: resp, err: err}
+		}()
+		return nil
+	}
+
+	if err := launch(primary); err != nil {
+		return nil, err
+	}
+	inFlight := 1
+
+	timer := time.NewTimer(b.hedgeDelay)
+	defer timer.Stop()
+	hedge := timer.C
+
+	var winner hedgeResult
+	for done := false; !done; {
+		select {
+		case <-hedge:
+			hedge = nil
+			if err := launch(b.hedgeBackup); err != nil {
+				cancels[0]()
+				return nil, err
+			}
+			inFlight++
+		case res := <-results:
+			inFlight--
+			if res.err != nil {
+				cancels[res.attempt]()
+				// A failed attempt only decides the outcome when there's nothing else left to wait on
+				if inFlight > 0 {
+					continue
+				}
+				return nil, res.err
+			}
+			winner, done = res, true
+		}
+	}
+
+	// Cancel whichever request lost the race and release its response if it still produces one
+	for attempt, cancel := range cancels {
+		if attempt != winner.attempt {
+			cancel()
+		}
+	}
+	// The winner's context must outlive this call since the caller still has to read the body, so it is cancelled
+	// when the body is closed instead
+	winner.resp.Body = &cancelOnCloseBody{ReadCloser: winner.resp.Body, cancel: cancels[winner.attempt]}
+
+	go func(inFlight int) {
+		for ; inFlight > 0; inFlight-- {
+			if loser := <-results; loser.resp != nil {
+				drainAndClose(loser.resp.Body)
+			}
+		}
+	}(inFlight)
+
+	return winner.resp, nil
+}
+
+type cancelOnCloseBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnCloseBody) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
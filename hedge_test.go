@@ -0,0 +1,58 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_WithHedging(t *testing.T) {
+	t.Run("Backup wins when the primary is slow", func(t *testing.T) {
+		ctx := context.Background()
+		primaryCancelled := make(chan error, 1)
+		primary := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			<-req.Context().Done()
+			primaryCancelled <- req.Context().Err()
+			return nil, req.Context().Err()
+		})
+		backup := httpmock.NewMock()
+		backup.GET(testUrl).Return(http.StatusOK, resp1, nil)
+
+		var out UserResponse
+		resp, err := New(http.MethodGet, testUrl, nil).
+			WithHedging(10*time.Millisecond, backup).
+			Do(ctx, primary, &out)
+		require.NoError(t, err)
+		require.NotEmpty(t, resp)
+		assert.Equal(t, resp1, out)
+		backup.AssertExpectations(t)
+
+		select {
+		case err := <-primaryCancelled:
+			assert.True(t, errors.Is(err, context.Canceled))
+		case <-time.After(time.Second):
+			t.Fatal("expected the primary request to be cancelled")
+		}
+	})
+	t.Run("Primary responding within the delay skips the backup", func(t *testing.T) {
+		ctx := context.Background()
+		primary := httpmock.NewMock()
+		primary.GET(testUrl).Return(http.StatusOK, resp1, nil)
+		backup := httpmock.NewMock()
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			WithHedging(time.Second, backup).
+			Do(ctx, primary, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		primary.AssertExpectations(t)
+		backup.AssertNotCalled(t, "Do")
+	})
+}
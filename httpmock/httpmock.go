@@ -2,20 +2,39 @@ package httpmock
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"io"
 	"io/ioutil"
+	"mime"
+	"net"
 	"net/http"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
+	"sync"
+	"syscall"
+	"time"
 
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
 
 const (
-	headerKeyContentType = "Content-Type"
+	headerKeyAuthorization   = "Authorization"
+	headerKeyContentEncoding = "Content-Encoding"
+	headerKeyContentType     = "Content-Type"
 
 	mimeApplicationJson = "application/json"
+	mimeApplicationXml  = "application/xml"
+	mimeTextCsv         = "text/csv"
+
+	encodingGzip = "gzip"
 )
 
 func NewMock() *Mock {
@@ -24,12 +43,140 @@ func NewMock() *Mock {
 
 type Mock struct {
 	mock.Mock
+
+	mu         sync.Mutex
+	logger     Logger
+	requests   []RecordedRequest
+	calls      []*HttpCall
+	unexpected []RecordedRequest
 }
 
 func (m *Mock) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	m.requests = append(m.requests, recorded)
+	calls := append([]*HttpCall(nil), m.calls...)
+	m.mu.Unlock()
+
+	// Unmatched requests are remembered before testify fails the call, so a recovered panic can't hide them
+	matched, mismatches := matchesAny(calls, req)
+	if !matched {
+		m.mu.Lock()
+		m.unexpected = append(m.unexpected, recorded)
+		m.mu.Unlock()
+
+		for _, mismatch := range mismatches {
+			m.logBodyDiff(req, mismatch.want, mismatch.got)
+		}
+	}
+
 	args := m.Called(req)
-	return args.Get(0).(*http.Response), args.Error(1)
+	if r, ok := args.Get(0).(responder); ok {
+		return r.respond(req)
+	}
+	resp, _ := args.Get(0).(*http.Response)
+	return replay(resp, req), args.Error(1)
+}
+
+// Requests returns every request the mock received, in order, whether or not it matched an expectation.
+func (m *Mock) Requests() []RecordedRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]RecordedRequest(nil), m.requests...)
+}
+
+// Logger receives the diagnostics of a verbose Mock. *testing.T satisfies it.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// Verbose makes the mock log a diff between the expected and actual body whenever a request's JSON body doesn't
+// match an expectation, explaining why the request was unexpected.
+func (m *Mock) Verbose(logger Logger) *Mock {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.logger = logger
+	return m
+}
+
+func (m *Mock) logBodyDiff(req *http.Request, want, got []byte) {
+	m.mu.Lock()
+	logger := m.logger
+	m.mu.Unlock()
+	if logger == nil {
+		return
+	}
 
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(indentJSON(want)),
+		B:        difflib.SplitLines(indentJSON(got)),
+		FromFile: "Expected",
+		ToFile:   "Actual",
+		Context:  1,
+	})
+	logger.Logf("httpmock: %s %s body does not match expectation:\n%s", req.Method, req.URL, diff)
+}
+
+func indentJSON(data []byte) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "  "); err != nil {
+		return string(data)
+	}
+	return buf.String() + "\n"
+}
+
+// UnexpectedRequests returns every request the mock received that matched none of its expectations, in order.
+func (m *Mock) UnexpectedRequests() []RecordedRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]RecordedRequest(nil), m.unexpected...)
+}
+
+// AssertNoUnexpectedCalls asserts that every request the mock received matched one of its expectations.
+func (m *Mock) AssertNoUnexpectedCalls(t assert.TestingT) bool {
+	unexpected := m.UnexpectedRequests()
+	if len(unexpected) == 0 {
+		return true
+	}
+
+	calls := make([]string, len(unexpected))
+	for i, recorded := range unexpected {
+		calls[i] = recorded.Request.Method + " " + recorded.Request.URL.String()
+	}
+	t.Errorf("httpmock: received %d unexpected request(s):\n\t%s", len(unexpected), strings.Join(calls, "\n\t"))
+	return false
+}
+
+// bodyMismatch is the expected and actual body of a request that matched an expectation in everything but its body.
+type bodyMismatch struct {
+	want, got []byte
+}
+
+// matchesAny reports whether req matches one of the expectations. If none does, it also returns the body mismatches
+// found along the way, so that a request matching a later expectation doesn't log diffs against the earlier ones.
+func matchesAny(calls []*HttpCall, req *http.Request) (bool, []bodyMismatch) {
+	var mismatches []bodyMismatch
+	onBodyMismatch := func(_ *http.Request, want, got []byte) {
+		mismatches = append(mismatches, bodyMismatch{want: want, got: got})
+	}
+
+	for _, call := range calls {
+		// testify won't match an expectation again once Once or Times is used up, so neither does the mock
+		if call.exhausted() {
+			continue
+		}
+		if makeRequestMatcherFunc(call.matchOn, onBodyMismatch)(req) {
+			return true, nil
+		}
+	}
+	return false, mismatches
 }
 
 func (m *Mock) GET(url string) *HttpCall {
@@ -42,8 +189,7 @@ func (m *Mock) GET(url string) *HttpCall {
 		Body:       nil,
 	}
 
-	requestMatcher := mock.MatchedBy(makeRequestMatcherFunc(matchOn))
-	return &HttpCall{m.On("Do", requestMatcher)}
+	return m.on(matchOn)
 }
 
 func (m *Mock) POST(url string, body interface{}) *HttpCall {
@@ -56,21 +202,149 @@ func (m *Mock) POST(url string, body interface{}) *HttpCall {
 		Body:       body,
 	}
 
-	requestMatcher := mock.MatchedBy(makeRequestMatcherFunc(matchOn))
-	return &HttpCall{m.On("Do", requestMatcher)}
+	return m.on(matchOn)
+}
+
+func (m *Mock) OPTIONS(url string) *HttpCall {
+	header := http.Header{}
+	header.Add(headerKeyContentType, mimeApplicationJson)
+	matchOn := MatchOn{
+		HttpMethod: http.MethodOptions,
+		Url:        url,
+		Header:     header,
+		Body:       nil,
+	}
+
+	return m.on(matchOn)
+}
+
+// Expect registers an expectation for requests matching matchOn, for tests that only verify the request, e.g. with
+// Run. Unless Return is called, matching requests get a 200 response with an empty body.
+func (m *Mock) Expect(matchOn MatchOn) *HttpCall {
+	if matchOn.Header == nil {
+		matchOn.Header = http.Header{}
+	}
+
+	call := m.on(matchOn)
+	call.Call.Return(newResponse(http.StatusOK, nil), nil)
+	return call
+}
+
+func (m *Mock) on(matchOn MatchOn) *HttpCall {
+	if matchOn.RequireHeader == nil {
+		matchOn.RequireHeader = http.Header{}
+	}
+
+	// The matcher holds on to the MatchOn so that HttpCall can refine it after the expectation is registered
+	call := &HttpCall{matchOn: &matchOn}
+	// Mismatches are only logged by the mock's own check in Do, as testify runs its matchers several times per call
+	m.mu.Lock()
+	m.calls = append(m.calls, call)
+	m.mu.Unlock()
+
+	call.Call = m.On("Do", mock.MatchedBy(makeRequestMatcherFunc(call.matchOn, nil)))
+	call.Run(func(*http.Request) {})
+	return call
 }
 
 type HttpCall struct {
 	*mock.Call
+	matchOn *MatchOn
+
+	mu       sync.Mutex
+	received []*http.Request
+	// times limits how many requests the expectation matches, zero meaning any number
+	times int
+}
+
+// Once expects the request to be made once. Further identical requests are unexpected.
+func (c *HttpCall) Once() *HttpCall {
+	return c.Times(1)
+}
+
+// Twice expects the request to be made twice. Further identical requests are unexpected.
+func (c *HttpCall) Twice() *HttpCall {
+	return c.Times(2)
+}
+
+// Times expects the request to be made n times. Further identical requests are unexpected.
+func (c *HttpCall) Times(n int) *HttpCall {
+	c.mu.Lock()
+	c.times = n
+	c.mu.Unlock()
+
+	c.Call.Times(n)
+	return c
+}
+
+// exhausted reports whether the expectation already matched as many requests as Once or Times allow.
+func (c *HttpCall) exhausted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.times > 0 && len(c.received) >= c.times
+}
+
+// WithMatchHeader requires the request to carry the header with exactly this value. Scoping expectations to a
+// unique header value keeps concurrent tests sharing a Mock from matching each other's calls.
+func (c *HttpCall) WithMatchHeader(key, value string) *HttpCall {
+	return c.requireHeader(key, value)
+}
+
+// WithBearerToken requires the request to carry an Authorization header with the given bearer token.
+func (c *HttpCall) WithBearerToken(token string) *HttpCall {
+	return c.requireHeader(headerKeyAuthorization, "Bearer "+token)
+}
+
+// WithBasicAuth requires the request to carry an Authorization header with the given basic auth credentials.
+func (c *HttpCall) WithBasicAuth(username, password string) *HttpCall {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return c.requireHeader(headerKeyAuthorization, "Basic "+credentials)
+}
+
+// WithContentType expects the request to be sent with the given content type instead of application/json. With
+// text/csv the expected body must be a [][]string, compared row by row with the request's CSV records.
+func (c *HttpCall) WithContentType(contentType string) *HttpCall {
+	return c.requireHeader(headerKeyContentType, contentType)
+}
+
+func (c *HttpCall) requireHeader(key, value string) *HttpCall {
+	c.matchOn.Header.Set(key, value)
+	c.matchOn.RequireHeader.Set(key, value)
+	return c
 }
 
 func (c *HttpCall) Run(run func(req *http.Request)) *HttpCall {
 	c.Call.Run(func(args mock.Arguments) {
-		run(args[0].(*http.Request))
+		req := args[0].(*http.Request)
+		c.mu.Lock()
+		c.received = append(c.received, req)
+		c.mu.Unlock()
+
+		run(req)
 	})
 	return c
 }
 
+// AssertHeader asserts that the expectation matched at least one request and that every request it matched carried
+// the header key with the expected value, such as the Authorization header sent by the code under test.
+func (c *HttpCall) AssertHeader(t assert.TestingT, key, expected string) bool {
+	c.mu.Lock()
+	received := append([]*http.Request(nil), c.received...)
+	c.mu.Unlock()
+
+	if len(received) == 0 {
+		t.Errorf("httpmock: expected a request with header %s: %q, but the expectation wasn't matched", key, expected)
+		return false
+	}
+
+	ok := true
+	for _, req := range received {
+		ok = assert.Equal(t, expected, req.Header.Get(key), "header %s of %s %s", key, req.Method, req.URL) && ok
+	}
+	return ok
+}
+
 func (c *HttpCall) Return(statusCode int, out interface{}, outErr error) *HttpCall {
 	// TODO Support multiple content types
 	data, err := json.Marshal(out)
@@ -78,19 +352,298 @@ func (c *HttpCall) Return(statusCode int, out interface{}, outErr error) *HttpCa
 		panic(err)
 	}
 
-	resp := &http.Response{
+	c.Call.Return(newResponse(statusCode, data), outErr)
+	return c
+}
+
+// MockResponse is one response in a sequence configured with ReturnSequence. Body is marshalled to JSON like the
+// body passed to Return.
+type MockResponse struct {
+	StatusCode int
+	Body       interface{}
+	Err        error
+}
+
+// ReturnSequence returns each response in turn on successive calls matching the expectation, repeating the last one
+// once the sequence is exhausted. Use Times to limit how often the expectation may match.
+func (c *HttpCall) ReturnSequence(responses ...MockResponse) *HttpCall {
+	if len(responses) == 0 {
+		panic("ReturnSequence requires at least one response")
+	}
+
+	seq := &sequence{}
+	for _, r := range responses {
+		data, err := json.Marshal(r.Body)
+		if err != nil {
+			panic(err)
+		}
+		seq.responses = append(seq.responses, newResponse(r.StatusCode, data))
+		seq.errs = append(seq.errs, r.Err)
+	}
+
+	c.Call.Return(seq, nil)
+	return c
+}
+
+// responder produces the response for a call at the time it's made, for expectations whose response varies.
+type responder interface {
+	respond(req *http.Request) (*http.Response, error)
+}
+
+type sequence struct {
+	mu        sync.Mutex
+	responses []*http.Response
+	errs      []error
+	next      int
+}
+
+func (s *sequence) respond(req *http.Request) (*http.Response, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	i := s.next
+	if i < len(s.responses)-1 {
+		s.next++
+	}
+	return replay(s.responses[i], req), s.errs[i]
+}
+
+// ReturnFromFile responds with the contents of the file at path, such as a fixture in testdata. The Content-Type is
+// inferred from the file extension, so .json files are served as application/json and .xml files as
+// application/xml.
+func (c *HttpCall) ReturnFromFile(statusCode int, path string) *HttpCall {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		panic(err)
+	}
+
+	resp := newResponse(statusCode, data)
+	switch ext := filepath.Ext(path); ext {
+	case ".json":
+		resp.Header.Set(headerKeyContentType, mimeApplicationJson)
+	case ".xml":
+		resp.Header.Set(headerKeyContentType, mimeApplicationXml)
+	default:
+		if contentType := mime.TypeByExtension(ext); contentType != "" {
+			resp.Header.Set(headerKeyContentType, contentType)
+		}
+	}
+
+	c.Call.Return(resp, nil)
+	return c
+}
+
+// ReturnGzipped is like Return, but gzips the marshalled body and sets Content-Encoding: gzip on the response.
+func (c *HttpCall) ReturnGzipped(statusCode int, out interface{}) *HttpCall {
+	data, err := json.Marshal(out)
+	if err != nil {
+		panic(err)
+	}
+
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	_, err = gw.Write(data)
+	if err != nil {
+		panic(err)
+	}
+	err = gw.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	resp := newResponse(statusCode, buf.Bytes())
+	resp.Header.Set(headerKeyContentEncoding, encodingGzip)
+	c.Call.Return(resp, nil)
+	return c
+}
+
+// ReturnError fails the call with err and no response, the way a Doer reports a request that never got a response.
+func (c *HttpCall) ReturnError(err error) *HttpCall {
+	c.Call.Return(nil, err)
+	return c
+}
+
+// ReturnTimeout fails the call with context.DeadlineExceeded, as if the request's deadline passed before a response
+// arrived. The error reports itself as a timeout through the net.Error interface.
+func (c *HttpCall) ReturnTimeout() *HttpCall {
+	return c.ReturnError(context.DeadlineExceeded)
+}
+
+// ReturnConnReset fails the call with a *net.OpError wrapping ECONNRESET, as if the server reset the connection while
+// the response was being read.
+func (c *HttpCall) ReturnConnReset() *HttpCall {
+	return c.ReturnError(&net.OpError{
+		Op:  "read",
+		Net: "tcp",
+		Err: os.NewSyscallError("read", syscall.ECONNRESET),
+	})
+}
+
+// ReturnStream responds with r as the body, read as the consumer reads the response rather than buffered up front, for
+// testing streaming and progress code. contentLength may be -1 if unknown. The reader is shared, so only the first
+// matching call sees its contents.
+func (c *HttpCall) ReturnStream(statusCode int, r io.Reader, contentLength int64) *HttpCall {
+	c.Call.Return(&stream{statusCode: statusCode, reader: r, contentLength: contentLength}, nil)
+	return c
+}
+
+// ReadDelay makes every read of a body configured with ReturnStream wait for d first, simulating a slow network.
+func (c *HttpCall) ReadDelay(d time.Duration) *HttpCall {
+	s, ok := c.returned().(*stream)
+	if !ok {
+		panic("ReadDelay must be called after ReturnStream")
+	}
+
+	s.delay = d
+	return c
+}
+
+// Delay makes matching calls wait for d before responding, simulating a slow server. Unlike testify's After, the wait
+// ends early when the request's context is done, in which case the call fails with the context's error. It must be
+// called after the response is configured.
+func (c *HttpCall) Delay(d time.Duration) *HttpCall {
+	if len(c.Call.ReturnArguments) == 0 {
+		panic("Delay must be called after Return")
+	}
+
+	if existing, ok := c.Call.ReturnArguments.Get(0).(*delayed); ok {
+		existing.delay = d
+		return c
+	}
+
+	c.Call.ReturnArguments = mock.Arguments{
+		&delayed{delay: d, response: c.Call.ReturnArguments.Get(0), err: c.Call.ReturnArguments.Error(1)},
+		nil,
+	}
+	return c
+}
+
+// returned is the response configured for the call, which is a *http.Response or a responder.
+func (c *HttpCall) returned() interface{} {
+	if len(c.Call.ReturnArguments) == 0 {
+		return nil
+	}
+
+	returned := c.Call.ReturnArguments.Get(0)
+	if d, ok := returned.(*delayed); ok {
+		return d.response
+	}
+	return returned
+}
+
+type delayed struct {
+	delay    time.Duration
+	response interface{}
+	err      error
+}
+
+func (d *delayed) respond(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(d.delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-timer.C:
+	}
+
+	if r, ok := d.response.(responder); ok {
+		return r.respond(req)
+	}
+	resp, _ := d.response.(*http.Response)
+	return replay(resp, req), d.err
+}
+
+type stream struct {
+	statusCode    int
+	reader        io.Reader
+	contentLength int64
+	delay         time.Duration
+}
+
+func (s *stream) respond(req *http.Request) (*http.Response, error) {
+	return &http.Response{
+		Status:        http.StatusText(s.statusCode),
+		StatusCode:    s.statusCode,
+		Header:        http.Header{},
+		Body:          &delayedReader{reader: s.reader, delay: s.delay},
+		ContentLength: s.contentLength,
+		Request:       req,
+	}, nil
+}
+
+type delayedReader struct {
+	reader io.Reader
+	delay  time.Duration
+}
+
+func (r *delayedReader) Read(p []byte) (int, error) {
+	if r.delay > 0 {
+		time.Sleep(r.delay)
+	}
+	return r.reader.Read(p)
+}
+
+func (r *delayedReader) Close() error {
+	return nil
+}
+
+func newResponse(statusCode int, data []byte) *http.Response {
+	return &http.Response{
 		Status:        http.StatusText(statusCode),
 		StatusCode:    statusCode,
-		Body:          io.NopCloser(bytes.NewReader(data)),
+		Header:        http.Header{},
+		Body:          newCannedBody(data),
 		ContentLength: int64(len(data)),
 	}
+}
 
-	c.Call.Return(resp, outErr)
-	return c
+// cannedBody is a response body that remembers its contents so that an expectation matched several times can hand
+// out an unread copy of its response each time.
+type cannedBody struct {
+	io.Reader
+	data []byte
+}
+
+func newCannedBody(data []byte) *cannedBody {
+	return &cannedBody{Reader: bytes.NewReader(data), data: data}
+}
+
+func (b *cannedBody) Close() error {
+	return nil
+}
+
+// replay returns a copy of a configured response with a fresh body, tied to the request that matched it.
+func replay(resp *http.Response, req *http.Request) *http.Response {
+	if resp == nil {
+		return nil
+	}
+
+	replayed := *resp
+	replayed.Header = resp.Header.Clone()
+	replayed.Request = req
+	if body, ok := resp.Body.(*cannedBody); ok {
+		replayed.Body = newCannedBody(body.data)
+	}
+
+	return &replayed
 }
 
+// AddHeader adds a header to the response configured by Return, so it must be called after Return.
 func (c *HttpCall) AddHeader(key, val string) *HttpCall {
-	//TODO
+	if len(c.Call.ReturnArguments) == 0 {
+		panic("AddHeader must be called after Return")
+	}
+
+	resp, ok := c.returned().(*http.Response)
+	if !ok {
+		panic("AddHeader is only supported for responses configured by Return")
+	}
+	resp.Header.Add(key, val)
 	return c
 }
 
@@ -99,14 +652,22 @@ type MatchOn struct {
 	Url        string
 	Header     http.Header
 	Body       interface{}
+	// RequireHeader lists headers that must be present on the request with exactly these values
+	RequireHeader http.Header
 }
 
-func makeRequestMatcherFunc(matchOn MatchOn) func(*http.Request) bool {
+func makeRequestMatcherFunc(matchOn *MatchOn, onBodyMismatch func(req *http.Request, want, got []byte)) func(*http.Request) bool {
 	return func(request *http.Request) bool {
 		if matchOn.HttpMethod != request.Method {
 			return false
 		}
 
+		for key, wantVals := range matchOn.RequireHeader {
+			if !reflect.DeepEqual(wantVals, request.Header.Values(key)) {
+				return false
+			}
+		}
+
 		// Need to compare the request header to the matchOn header by
 		// 1. Asserting all keys in the request header are in the matchOn header.
 		// 2. Asserting that the length of the values for the key is the same
@@ -127,11 +688,11 @@ func makeRequestMatcherFunc(matchOn MatchOn) func(*http.Request) bool {
 			}
 		}
 
-		return checkBodyMatch(request, matchOn.Body)
+		return checkBodyMatch(request, matchOn.Body, onBodyMismatch)
 	}
 }
 
-func checkBodyMatch(request *http.Request, wantBody interface{}) bool {
+func checkBodyMatch(request *http.Request, wantBody interface{}, onMismatch func(req *http.Request, want, got []byte)) bool {
 	if (request.Body == nil || request.Body == http.NoBody) && wantBody == nil {
 		return true
 	}
@@ -151,6 +712,11 @@ func checkBodyMatch(request *http.Request, wantBody interface{}) bool {
 	}
 	request.Body = io.NopCloser(bytes.NewReader(reqBodyBytes))
 
+	mediaType, _, _ := mime.ParseMediaType(request.Header.Get(headerKeyContentType))
+	if mediaType == mimeTextCsv {
+		return checkCSVBodyMatch(reqBodyBytes, wantBody)
+	}
+
 	// Unmarshal the body as a json.RawMessage and then marshal it again to ensure that order of keys does not
 	// affect the equality check
 	var body json.RawMessage
@@ -169,5 +735,25 @@ func checkBodyMatch(request *http.Request, wantBody interface{}) bool {
 		panic(err)
 	}
 
-	return bytes.Compare(expectedBodyBytes, actualBodyBytes) == 0
+	if bytes.Compare(expectedBodyBytes, actualBodyBytes) != 0 {
+		if onMismatch != nil {
+			onMismatch(request, expectedBodyBytes, actualBodyBytes)
+		}
+		return false
+	}
+	return true
+}
+
+func checkCSVBodyMatch(reqBodyBytes []byte, wantBody interface{}) bool {
+	wantRows, ok := wantBody.([][]string)
+	if !ok {
+		panic("expected a [][]string body for csv body match")
+	}
+
+	rows, err := csv.NewReader(bytes.NewReader(reqBodyBytes)).ReadAll()
+	if err != nil {
+		return false
+	}
+
+	return reflect.DeepEqual(wantRows, rows)
 }
@@ -1,8 +1,19 @@
 package httpmock
 
 import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -39,3 +50,420 @@ func TestNewMock(t *testing.T) {
 	assert.NotEmpty(t, resp)
 	mock.AssertExpectations(t)
 }
+
+func TestHttpCall_AddHeader(t *testing.T) {
+	mock := NewMock()
+	mock.OPTIONS("http://example.com").
+		Return(http.StatusNoContent, nil, nil).
+		AddHeader("Allow", "GET").
+		AddHeader("Allow", "POST")
+
+	req, err := http.NewRequest(http.MethodOptions, "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := mock.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	assert.Equal(t, []string{"GET", "POST"}, resp.Header.Values("Allow"))
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_WithBearerToken(t *testing.T) {
+	mock := NewMock()
+	mock.GET("http://example.com").WithBearerToken("abc").Return(http.StatusOK, nil, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer xyz")
+	assert.Panics(t, func() { _, _ = mock.Do(req) }, "expected a request with a different token not to match")
+
+	req.Header.Set("Authorization", "Bearer abc")
+	resp, err := mock.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_WithBasicAuth(t *testing.T) {
+	mock := NewMock()
+	mock.GET("http://example.com").WithBasicAuth("jack", "secret").Return(http.StatusOK, nil, nil)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	assert.Panics(t, func() { _, _ = mock.Do(req) }, "expected a request without credentials not to match")
+
+	req.SetBasicAuth("jack", "secret")
+	_, err = mock.Do(req)
+	require.NoError(t, err)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_AssertHeader(t *testing.T) {
+	mock := NewMock()
+	var ran bool
+	call := mock.Expect(MatchOn{
+		HttpMethod: http.MethodGet,
+		Url:        "http://example.com",
+		Header:     http.Header{"Authorization": {"Bearer abc"}},
+	}).Run(func(req *http.Request) { ran = true })
+
+	collector := &errorCollector{}
+	assert.False(t, call.AssertHeader(collector, "Authorization", "Bearer abc"), "expected an unmatched call to fail")
+	require.Len(t, collector.errors, 1)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer abc")
+	_, err = mock.Do(req)
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	assert.True(t, call.AssertHeader(t, "Authorization", "Bearer abc"))
+	collector = &errorCollector{}
+	assert.False(t, call.AssertHeader(collector, "Authorization", "Bearer xyz"))
+	assert.Len(t, collector.errors, 1)
+	mock.AssertExpectations(t)
+}
+
+func TestMock_Requests(t *testing.T) {
+	mock := NewMock()
+	mock.POST("http://example.com", InputData{ID: "1"}).Return(http.StatusOK, nil, nil)
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(`{"id":"1","name":"","age":0}`))
+	require.NoError(t, err)
+	_, err = mock.Do(req)
+	require.NoError(t, err)
+
+	requests := mock.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPost, requests[0].Request.Method)
+	assert.JSONEq(t, `{"id":"1","name":"","age":0}`, string(requests[0].Body))
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_WithMatchHeader(t *testing.T) {
+	mock := NewMock()
+	mock.GET("http://example.com").WithMatchHeader("X-Test-Id", "first").Return(http.StatusOK, OutputData{FirstName: "first"}, nil)
+	mock.GET("http://example.com").WithMatchHeader("X-Test-Id", "second").Return(http.StatusOK, OutputData{FirstName: "second"}, nil)
+
+	var wg sync.WaitGroup
+	for _, testID := range []string{"first", "second", "first", "second"} {
+		wg.Add(1)
+		go func(testID string) {
+			defer wg.Done()
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			require.NoError(t, err)
+			req.Header.Set("X-Test-Id", testID)
+
+			resp, err := mock.Do(req)
+			require.NoError(t, err)
+			var out OutputData
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+			assert.Equal(t, testID, out.FirstName)
+		}(testID)
+	}
+	wg.Wait()
+	mock.AssertExpectations(t)
+}
+
+type errorCollector struct {
+	errors []string
+}
+
+func (c *errorCollector) Errorf(format string, args ...interface{}) {
+	c.errors = append(c.errors, fmt.Sprintf(format, args...))
+}
+
+func TestMock_AssertNoUnexpectedCalls(t *testing.T) {
+	t.Run("Requests matching no expectation are unexpected", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com").Return(http.StatusOK, OutputData{FirstName: "Jack"}, nil)
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		_, err = mock.Do(req)
+		require.NoError(t, err)
+		assert.True(t, mock.AssertNoUnexpectedCalls(t))
+
+		req, err = http.NewRequest(http.MethodDelete, "http://example.com/users/1", nil)
+		require.NoError(t, err)
+		assert.Panics(t, func() { _, _ = mock.Do(req) })
+
+		collector := &errorCollector{}
+		assert.False(t, mock.AssertNoUnexpectedCalls(collector))
+		require.Len(t, collector.errors, 1)
+		assert.Contains(t, collector.errors[0], "DELETE http://example.com/users/1")
+		require.Len(t, mock.UnexpectedRequests(), 1)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Requests beyond Once are unexpected", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com").Return(http.StatusOK, OutputData{FirstName: "Jack"}, nil).Once()
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		_, err = mock.Do(req)
+		require.NoError(t, err)
+		assert.Panics(t, func() { _, _ = mock.Do(req) })
+
+		collector := &errorCollector{}
+		assert.False(t, mock.AssertNoUnexpectedCalls(collector))
+		require.Len(t, mock.UnexpectedRequests(), 1)
+	})
+	t.Run("Requests beyond Times are unexpected", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com").Return(http.StatusOK, OutputData{FirstName: "Jack"}, nil).Times(2)
+
+		for i := 0; i < 2; i++ {
+			req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+			require.NoError(t, err)
+			_, err = mock.Do(req)
+			require.NoError(t, err)
+		}
+		assert.True(t, mock.AssertNoUnexpectedCalls(t))
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		assert.Panics(t, func() { _, _ = mock.Do(req) })
+		assert.False(t, mock.AssertNoUnexpectedCalls(&errorCollector{}))
+	})
+}
+
+func TestHttpCall_ReturnSequence(t *testing.T) {
+	mock := NewMock()
+	mock.GET("http://example.com").ReturnSequence(
+		MockResponse{StatusCode: http.StatusServiceUnavailable},
+		MockResponse{StatusCode: http.StatusOK, Body: OutputData{FirstName: "Jack"}},
+	).Times(3)
+
+	var statuses []int
+	for i := 0; i < 3; i++ {
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		resp, err := mock.Do(req)
+		require.NoError(t, err)
+		statuses = append(statuses, resp.StatusCode)
+	}
+
+	assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusOK, http.StatusOK}, statuses)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_WithContentType(t *testing.T) {
+	mock := NewMock()
+	mock.POST("http://example.com", [][]string{{"id", "name"}, {"1", "Jack"}}).
+		WithContentType("text/csv").
+		Return(http.StatusOK, nil, nil)
+
+	newRequest := func(body string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "text/csv")
+		return req
+	}
+
+	assert.Panics(t, func() { _, _ = mock.Do(newRequest("id,name\n2,Sam\n")) }, "expected different rows not to match")
+	_, err := mock.Do(newRequest("id,name\r\n1,Jack\r\n"))
+	require.NoError(t, err)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_ReturnGzipped(t *testing.T) {
+	mock := NewMock()
+	mock.GET("http://example.com").ReturnGzipped(http.StatusOK, OutputData{FirstName: "Jack"})
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := mock.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "gzip", resp.Header.Get("Content-Encoding"))
+
+	gr, err := gzip.NewReader(resp.Body)
+	require.NoError(t, err)
+	var out OutputData
+	require.NoError(t, json.NewDecoder(gr).Decode(&out))
+	assert.Equal(t, "Jack", out.FirstName)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_ReturnFromFile(t *testing.T) {
+	mock := NewMock()
+	mock.GET("http://example.com").ReturnFromFile(http.StatusOK, "testdata/user.json")
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := mock.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+
+	var out OutputData
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+	assert.Equal(t, "Jack", out.FirstName)
+	assert.Equal(t, "Ramey", out.LastName)
+	assert.Equal(t, 34, out.Info.Age)
+	mock.AssertExpectations(t)
+}
+
+type capturingLogger struct {
+	lines []string
+}
+
+func (l *capturingLogger) Logf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestMock_Verbose(t *testing.T) {
+	t.Run("Unmatched body is logged as a diff", func(t *testing.T) {
+		logger := &capturingLogger{}
+		mock := NewMock().Verbose(logger)
+		mock.POST("http://example.com", InputData{ID: "1", Name: "Jack", Age: 34}).Return(http.StatusOK, nil, nil)
+
+		req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(`{"id":"1","name":"Jack","age":35}`))
+		require.NoError(t, err)
+		assert.Panics(t, func() { _, _ = mock.Do(req) })
+
+		require.Len(t, logger.lines, 1)
+		assert.Contains(t, logger.lines[0], "POST http://example.com body does not match expectation")
+		assert.Contains(t, logger.lines[0], `-  "age": 34`)
+		assert.Contains(t, logger.lines[0], `+  "age": 35`)
+	})
+	t.Run("Nothing is logged when a later expectation matches", func(t *testing.T) {
+		logger := &capturingLogger{}
+		mock := NewMock().Verbose(logger)
+		mock.POST("http://example.com", InputData{ID: "1", Name: "Jack", Age: 34}).Return(http.StatusOK, nil, nil)
+		mock.POST("http://example.com", InputData{ID: "1", Name: "Jack", Age: 35}).Return(http.StatusOK, nil, nil)
+
+		req, err := http.NewRequest(http.MethodPost, "http://example.com", strings.NewReader(`{"id":"1","name":"Jack","age":35}`))
+		require.NoError(t, err)
+		_, err = mock.Do(req)
+		require.NoError(t, err)
+
+		assert.Empty(t, logger.lines)
+	})
+}
+
+func TestMock_Expect(t *testing.T) {
+	mock := NewMock()
+	var received *http.Request
+	mock.Expect(MatchOn{
+		HttpMethod: http.MethodPost,
+		Url:        "http://example.com",
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       InputData{ID: "1", Name: "Jack", Age: 34},
+	}).Run(func(req *http.Request) {
+		received = req
+		assert.Equal(t, "/users", req.URL.Path)
+	})
+
+	req, err := http.NewRequest(http.MethodPost, "http://example.com/users", strings.NewReader(`{"id":"1","name":"Jack","age":34}`))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := mock.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Empty(t, body)
+	require.NotNil(t, received)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_ReturnStream(t *testing.T) {
+	pr, pw := io.Pipe()
+	mock := NewMock()
+	mock.GET("http://example.com").ReturnStream(http.StatusOK, pr, 10).ReadDelay(time.Millisecond)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	resp, err := mock.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, int64(10), resp.ContentLength)
+
+	// Each chunk is only written once the previous one was read, so the body can't have been buffered
+	go func() {
+		for _, chunk := range []string{"hello", "world"} {
+			_, _ = pw.Write([]byte(chunk))
+		}
+		_ = pw.Close()
+	}()
+
+	buf := make([]byte, 5)
+	for _, want := range []string{"hello", "world"} {
+		_, err := io.ReadFull(resp.Body, buf)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(buf))
+	}
+	n, err := resp.Body.Read(buf)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, io.EOF, err)
+	mock.AssertExpectations(t)
+}
+
+func TestHttpCall_ReturnNetworkErrors(t *testing.T) {
+	t.Run("Timeout", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com/timeout").ReturnTimeout()
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com/timeout", nil)
+		require.NoError(t, err)
+		resp, err := mock.Do(req)
+		assert.Nil(t, resp)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+		var netErr net.Error
+		require.True(t, errors.As(err, &netErr))
+		assert.True(t, netErr.Timeout())
+		mock.AssertExpectations(t)
+	})
+	t.Run("Connection reset", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com/reset").ReturnConnReset()
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com/reset", nil)
+		require.NoError(t, err)
+		resp, err := mock.Do(req)
+		assert.Nil(t, resp)
+		assert.True(t, errors.Is(err, syscall.ECONNRESET))
+
+		var opErr *net.OpError
+		require.True(t, errors.As(err, &opErr))
+		assert.False(t, opErr.Timeout())
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestHttpCall_Delay(t *testing.T) {
+	t.Run("Response is returned once the delay elapses", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com").Return(http.StatusOK, OutputData{FirstName: "Jack"}, nil).
+			Delay(10*time.Millisecond).
+			AddHeader("X-Request-Id", "abc")
+
+		req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		start := time.Now()
+		resp, err := mock.Do(req)
+		require.NoError(t, err)
+		assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "abc", resp.Header.Get("X-Request-Id"))
+		mock.AssertExpectations(t)
+	})
+	t.Run("Cancelling the context ends the call", func(t *testing.T) {
+		mock := NewMock()
+		mock.GET("http://example.com").Return(http.StatusOK, nil, nil).Delay(time.Minute)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://example.com", nil)
+		require.NoError(t, err)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		start := time.Now()
+		resp, err := mock.Do(req)
+		assert.Nil(t, resp)
+		assert.Equal(t, context.Canceled, err)
+		assert.Less(t, time.Since(start), 5*time.Second)
+		mock.AssertExpectations(t)
+	})
+}
@@ -0,0 +1,78 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// Recorder is a Doer that answers every request with the same canned response and records each request it receives
+// so it can be inspected afterward. Unlike Mock, it has no expectations.
+type Recorder struct {
+	statusCode int
+	data       []byte
+
+	mu       sync.Mutex
+	requests []RecordedRequest
+}
+
+// RecordedRequest is a request received by a Recorder or Mock along with a copy of its body.
+type RecordedRequest struct {
+	Request *http.Request
+	Body    []byte
+}
+
+func NewRecorder(statusCode int, out interface{}) *Recorder {
+	data, err := json.Marshal(out)
+	if err != nil {
+		panic(err)
+	}
+
+	return &Recorder{
+		statusCode: statusCode,
+		data:       data,
+	}
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	r.requests = append(r.requests, recorded)
+	r.mu.Unlock()
+
+	resp := newResponse(r.statusCode, r.data)
+	resp.Request = req
+	return resp, nil
+}
+
+// recordRequest captures a copy of the request body, replacing it with an unread copy so the request can still be
+// consumed as usual.
+func recordRequest(req *http.Request) (RecordedRequest, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return RecordedRequest{Request: req}, nil
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return RecordedRequest{}, err
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	return RecordedRequest{Request: req, Body: body}, nil
+}
+
+// Requests returns the requests received so far, in the order they were made.
+func (r *Recorder) Requests() []RecordedRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]RecordedRequest(nil), r.requests...)
+}
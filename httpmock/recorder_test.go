@@ -0,0 +1,34 @@
+package httpmock
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	recorder := NewRecorder(http.StatusOK, OutputData{FirstName: "Jack"})
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/users", nil)
+	require.NoError(t, err)
+	resp, err := recorder.Do(req)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	req, err = http.NewRequest(http.MethodPost, "http://example.com/users", bytes.NewReader([]byte(`{"id":"1"}`)))
+	require.NoError(t, err)
+	_, err = recorder.Do(req)
+	require.NoError(t, err)
+
+	requests := recorder.Requests()
+	require.Len(t, requests, 2)
+	assert.Equal(t, http.MethodGet, requests[0].Request.Method)
+	assert.Equal(t, "http://example.com/users", requests[0].Request.URL.String())
+	assert.Empty(t, requests[0].Body)
+	assert.Equal(t, http.MethodPost, requests[1].Request.Method)
+	assert.Equal(t, "http://example.com/users", requests[1].Request.URL.String())
+	assert.Equal(t, `{"id":"1"}`, string(requests[1].Body))
+}
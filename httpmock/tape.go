@@ -0,0 +1,214 @@
+package httpmock
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"unicode/utf8"
+)
+
+// Doer is anything that sends HTTP requests, such as an http.Client, a Mock, or a Recorder.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// Interaction is a request and the response it received, as stored on a Tape.
+type Interaction struct {
+	Request  TapeRequest  `json:"request"`
+	Response TapeResponse `json:"response"`
+}
+
+type TapeRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   TapeBody    `json:"body,omitempty"`
+}
+
+type TapeResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       TapeBody    `json:"body,omitempty"`
+}
+
+// TapeBody is a recorded body. Text bodies are saved as plain strings so that tapes can be read and diffed, while
+// binary bodies are saved as an object holding the base64 encoded bytes.
+type TapeBody []byte
+
+type binaryTapeBody struct {
+	Base64 []byte `json:"base64"`
+}
+
+func (b TapeBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(binaryTapeBody{Base64: b})
+}
+
+func (b *TapeBody) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		var binary binaryTapeBody
+		err := json.Unmarshal(data, &binary)
+		if err != nil {
+			return err
+		}
+		*b = binary.Base64
+		return nil
+	}
+
+	var text string
+	err := json.Unmarshal(data, &text)
+	if err != nil {
+		return err
+	}
+	*b = TapeBody(text)
+	return nil
+}
+
+// redactedHeaders are the headers whose values are replaced before an interaction is recorded, so that credentials
+// don't end up in golden files.
+var redactedHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "X-Api-Key"}
+
+const redactedValue = "REDACTED"
+
+// Tape is a Doer for golden tests. A tape created with NewTape records every request sent through it, along with the
+// response, and can be saved. A tape loaded with LoadTape replays the saved responses without sending anything.
+type Tape struct {
+	doer   Doer
+	redact []string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	played       []bool
+}
+
+// NewTape returns a Tape that sends requests with doer and records each exchange. Requests that fail with an error
+// aren't recorded. The values of credential headers, such as Authorization and Cookie, are recorded as REDACTED.
+func NewTape(doer Doer) *Tape {
+	return &Tape{doer: doer, redact: redactedHeaders}
+}
+
+// RedactHeaders adds headers whose values are recorded as REDACTED, such as a service's own API key header.
+func (t *Tape) RedactHeaders(names ...string) *Tape {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.redact = append(append([]string(nil), t.redact...), names...)
+	return t
+}
+
+// redacted returns a copy of header with the values of the redacted headers replaced.
+func (t *Tape) redacted(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range t.redact {
+		if vals := header.Values(name); len(vals) > 0 {
+			redacted := make([]string, len(vals))
+			for i := range redacted {
+				redacted[i] = redactedValue
+			}
+			header[http.CanonicalHeaderKey(name)] = redacted
+		}
+	}
+	return header
+}
+
+// LoadTape returns a Tape that replays the interactions previously written by Save. Each interaction is replayed once,
+// for the first request with the same method and URL, so repeated requests get their responses in recorded order.
+func LoadTape(r io.Reader) (*Tape, error) {
+	var interactions []Interaction
+	err := json.NewDecoder(r).Decode(&interactions)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode tape: %v", err)
+	}
+
+	return &Tape{interactions: interactions, played: make([]bool, len(interactions))}, nil
+}
+
+func (t *Tape) Do(req *http.Request) (*http.Response, error) {
+	if t.doer == nil {
+		return t.replay(req)
+	}
+	return t.record(req)
+}
+
+func (t *Tape) record(req *http.Request) (*http.Response, error) {
+	recorded, err := recordRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.doer.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := ioutil.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = newCannedBody(body)
+
+	t.mu.Lock()
+	t.interactions = append(t.interactions, Interaction{
+		Request: TapeRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: t.redacted(req.Header),
+			Body:   recorded.Body,
+		},
+		Response: TapeResponse{
+			StatusCode: resp.StatusCode,
+			Header:     t.redacted(resp.Header),
+			Body:       body,
+		},
+	})
+	t.mu.Unlock()
+
+	return resp, nil
+}
+
+func (t *Tape) replay(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	url := req.URL.String()
+	for i, interaction := range t.interactions {
+		if t.played[i] || interaction.Request.Method != req.Method || interaction.Request.URL != url {
+			continue
+		}
+		t.played[i] = true
+
+		resp := newResponse(interaction.Response.StatusCode, interaction.Response.Body)
+		if interaction.Response.Header != nil {
+			resp.Header = interaction.Response.Header.Clone()
+		}
+		resp.Request = req
+		return resp, nil
+	}
+
+	return nil, fmt.Errorf("httpmock: no recorded response for %s %s", req.Method, url)
+}
+
+// Interactions returns the interactions on the tape, in the order they were recorded.
+func (t *Tape) Interactions() []Interaction {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]Interaction(nil), t.interactions...)
+}
+
+// Save writes the recorded interactions to w as JSON, in the format read by LoadTape.
+func (t *Tape) Save(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	err := enc.Encode(t.Interactions())
+	if err != nil {
+		return fmt.Errorf("unable to encode tape: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package httpmock
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTape(t *testing.T) {
+	backend := NewMock()
+	backend.GET("http://example.com/users/1").Return(http.StatusOK, OutputData{FirstName: "Jack", LastName: "Ramey"}, nil).Once()
+	backend.POST("http://example.com/users", InputData{ID: "2", Name: "Sam"}).
+		Return(http.StatusCreated, OutputData{FirstName: "Sam"}, nil).Once()
+
+	send := func(doer Doer) []OutputData {
+		get, err := http.NewRequest(http.MethodGet, "http://example.com/users/1", nil)
+		require.NoError(t, err)
+		post, err := http.NewRequest(http.MethodPost, "http://example.com/users", strings.NewReader(`{"id":"2","name":"Sam","age":0}`))
+		require.NoError(t, err)
+
+		var results []OutputData
+		for _, req := range []*http.Request{get, post} {
+			resp, err := doer.Do(req)
+			require.NoError(t, err)
+			var out OutputData
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+			require.NoError(t, resp.Body.Close())
+			results = append(results, out)
+		}
+		return results
+	}
+
+	// Recording sends requests to the backend and stores every exchange
+	recording := NewTape(backend)
+	recorded := send(recording)
+	assert.Equal(t, []OutputData{{FirstName: "Jack", LastName: "Ramey"}, {FirstName: "Sam"}}, recorded)
+	backend.AssertExpectations(t)
+
+	interactions := recording.Interactions()
+	require.Len(t, interactions, 2)
+	assert.Equal(t, http.MethodPost, interactions[1].Request.Method)
+	assert.JSONEq(t, `{"id":"2","name":"Sam","age":0}`, string(interactions[1].Request.Body))
+	assert.Equal(t, http.StatusCreated, interactions[1].Response.StatusCode)
+
+	var saved bytes.Buffer
+	require.NoError(t, recording.Save(&saved))
+
+	// Replaying serves the saved responses without the backend, which would fail any further request
+	replaying, err := LoadTape(&saved)
+	require.NoError(t, err)
+	assert.Equal(t, recorded, send(replaying))
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/users/1", nil)
+	require.NoError(t, err)
+	_, err = replaying.Do(req)
+	assert.EqualError(t, err, "httpmock: no recorded response for GET http://example.com/users/1")
+}
+
+func TestTape_redactsHeaders(t *testing.T) {
+	backend := NewMock()
+	backend.Expect(MatchOn{
+		HttpMethod: http.MethodGet,
+		Header:     http.Header{"Authorization": {"Bearer secret"}, "X-Tenant-Key": {"tenant-secret"}},
+	})
+
+	tape := NewTape(backend).RedactHeaders("X-Tenant-Key")
+	req, err := http.NewRequest(http.MethodGet, "http://example.com/users/1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("X-Tenant-Key", "tenant-secret")
+	_, err = tape.Do(req)
+	require.NoError(t, err)
+
+	// The request sent to the backend keeps its credentials, only the recording is redacted
+	assert.Equal(t, "Bearer secret", req.Header.Get("Authorization"))
+	interactions := tape.Interactions()
+	require.Len(t, interactions, 1)
+	assert.Equal(t, "REDACTED", interactions[0].Request.Header.Get("Authorization"))
+	assert.Equal(t, "REDACTED", interactions[0].Request.Header.Get("X-Tenant-Key"))
+
+	var saved bytes.Buffer
+	require.NoError(t, tape.Save(&saved))
+	assert.NotContains(t, saved.String(), "secret")
+}
+
+func TestTape_Save(t *testing.T) {
+	binary := []byte{0xff, 0x00, 0xfe}
+	backend := NewMock()
+	backend.POST("http://example.com/users", InputData{ID: "2", Name: "Sam"}).
+		Return(http.StatusCreated, OutputData{FirstName: "Sam"}, nil)
+	backend.Expect(MatchOn{HttpMethod: http.MethodGet}).ReturnStream(http.StatusOK, bytes.NewReader(binary), int64(len(binary)))
+
+	tape := NewTape(backend)
+	post, err := http.NewRequest(http.MethodPost, "http://example.com/users", strings.NewReader(`{"id":"2","name":"Sam","age":0}`))
+	require.NoError(t, err)
+	_, err = tape.Do(post)
+	require.NoError(t, err)
+	get, err := http.NewRequest(http.MethodGet, "http://example.com/avatar", nil)
+	require.NoError(t, err)
+	_, err = tape.Do(get)
+	require.NoError(t, err)
+
+	var saved bytes.Buffer
+	require.NoError(t, tape.Save(&saved))
+
+	// Text bodies are saved as strings, so the golden file shows them as sent
+	var raw []struct {
+		Request  struct{ Body json.RawMessage }
+		Response struct{ Body json.RawMessage }
+	}
+	require.NoError(t, json.Unmarshal(saved.Bytes(), &raw))
+	require.Len(t, raw, 2)
+	assert.JSONEq(t, `"{\"id\":\"2\",\"name\":\"Sam\",\"age\":0}"`, string(raw[0].Request.Body))
+	assert.JSONEq(t, `{"base64":"/wD+"}`, string(raw[1].Response.Body))
+
+	loaded, err := LoadTape(&saved)
+	require.NoError(t, err)
+	interactions := loaded.Interactions()
+	require.Len(t, interactions, 2)
+	assert.Equal(t, `{"id":"2","name":"Sam","age":0}`, string(interactions[0].Request.Body))
+	assert.Equal(t, binary, []byte(interactions[1].Response.Body))
+}
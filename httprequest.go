@@ -3,68 +3,447 @@ package httprequest
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math/rand"
 	"mime"
 	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 )
 
 const (
-	MIMEApplicationJson = "application/json"
-	MIMEApplicationXml  = "application/xml"
-	MIMETextXml         = "text/xml"
+	MIMEApplicationJson        = "application/json"
+	MIMEApplicationProblemJson = "application/problem+json"
+	MIMEMergePatchJson         = "application/merge-patch+json"
+	MIMEApplicationNDJSON      = "application/x-ndjson"
+	MIMEApplicationXml         = "application/xml"
+	MIMETextXml                = "text/xml"
+	MIMETextCsv                = "text/csv"
+	MIMETextEventStream        = "text/event-stream"
 
-	HeaderAuthorization = "Authorization"
-	HeaderContentType   = "Content-Type"
+	HeaderAccept             = "Accept"
+	HeaderAcceptEncoding     = "Accept-Encoding"
+	HeaderAcceptPost         = "Accept-Post"
+	HeaderAuthorization      = "Authorization"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderContentDisposition = "Content-Disposition"
+	HeaderContentEncoding    = "Content-Encoding"
+	HeaderContentLength      = "Content-Length"
+	HeaderContentType        = "Content-Type"
+	HeaderETag               = "ETag"
+	HeaderExpect             = "Expect"
+	HeaderExpires            = "Expires"
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIfMatch            = "If-Match"
+	HeaderIfNoneMatch        = "If-None-Match"
+	HeaderLink               = "Link"
+	HeaderLocation           = "Location"
+	HeaderOrigin             = "Origin"
+	HeaderReferer            = "Referer"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderUserAgent          = "User-Agent"
+	HeaderVary               = "Vary"
 )
 
+var (
+	defaultContentTypeMu sync.RWMutex
+	defaultContentType   = MIMEApplicationJson
+
+	defaultBaseURLMu sync.RWMutex
+	defaultBaseURL   string
+)
+
+// SetDefaultContentType changes the content type used by builders created with New from then on. Calling ContentType
+// on a builder still overrides it.
+func SetDefaultContentType(contentType string) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("unable to parse media type: %v", err)
+	}
+
+	switch mediaType {
+	case MIMEApplicationJson, MIMEMergePatchJson, MIMEApplicationXml, MIMETextXml:
+	default:
+		return fmt.Errorf("unsupported content type: %s", mediaType)
+	}
+
+	defaultContentTypeMu.Lock()
+	defaultContentType = contentType
+	defaultContentTypeMu.Unlock()
+	return nil
+}
+
+// DefaultBaseURL sets the base that relative URLs given to builders created with New from then on are resolved
+// against, so that an SDK can pass only a path. The URL's path is joined onto the base's, and URLs that are already
+// absolute are used as-is. An empty base removes it.
+func DefaultBaseURL(base string) error {
+	if base != "" {
+		u, err := url.Parse(base)
+		if err != nil {
+			return fmt.Errorf("unable to parse base url: %v", err)
+		}
+		if !u.IsAbs() || u.Host == "" {
+			return fmt.Errorf("base url must be absolute: %q", base)
+		}
+	}
+
+	defaultBaseURLMu.Lock()
+	defaultBaseURL = base
+	defaultBaseURLMu.Unlock()
+	return nil
+}
+
 func New(httpMethod, url string, body interface{}) *RequestBuilder {
+	defaultContentTypeMu.RLock()
+	contentType := defaultContentType
+	defaultContentTypeMu.RUnlock()
+
+	defaultBaseURLMu.RLock()
+	baseURL := defaultBaseURL
+	defaultBaseURLMu.RUnlock()
+
 	return &RequestBuilder{
 		body:                body,
 		url:                 url,
+		baseURL:             baseURL,
 		httpMethod:          httpMethod,
 		expectedStatusCodes: []int{http.StatusOK},
-		contentType:         MIMEApplicationJson,
+		contentType:         contentType,
 	}
 }
 
+// NewURL is like New, but takes an already parsed URL, which is sent as-is instead of being formatted and parsed again
+// unless query parameters or a base URL have to be applied. The URL is copied, so changing it afterwards doesn't affect
+// the builder. Braces in a parsed path are escaped, so use New for URLs with PathParam placeholders.
+func NewURL(httpMethod string, u *url.URL, body interface{}) *RequestBuilder {
+	b := New(httpMethod, u.String(), body)
+	b.parsedURL = cloneURL(u)
+	return b
+}
+
+func cloneURL(u *url.URL) *url.URL {
+	cloned := *u
+	if u.User != nil {
+		user := *u.User
+		cloned.User = &user
+	}
+	return &cloned
+}
+
+func Get(url string) *RequestBuilder {
+	return New(http.MethodGet, url, nil)
+}
+
+func Post(url string, body interface{}) *RequestBuilder {
+	return New(http.MethodPost, url, body)
+}
+
+func Put(url string, body interface{}) *RequestBuilder {
+	return New(http.MethodPut, url, body)
+}
+
+func Patch(url string, body interface{}) *RequestBuilder {
+	return New(http.MethodPatch, url, body)
+}
+
+func Delete(url string) *RequestBuilder {
+	return New(http.MethodDelete, url, nil)
+}
+
 type RequestBuilder struct {
 	body                interface{}
 	url                 string
+	parsedURL           *url.URL
+	baseURL             string
 	httpMethod          string
 	contentType         string
+	contentTypeSet      bool
+	contentEncoding     string
+	acceptGzip          bool
 	expectedStatusCodes []int
+	expectedStatusSet   bool
+	decodeTargets       map[int]interface{}
+	errorOut            interface{}
 	header              http.Header
+	trailer             http.Header
+	chunked             bool
+	pathParams          map[string]string
+	query               url.Values
+	queryBody           bool
+	host                string
+	encoder             func(interface{}) ([]byte, error)
+	decoders            map[string]func([]byte, interface{}) error
+	decodePath          string
+	requiredFields      []string
+	enforceAccept       bool
+	formParts           []formPart
+	expectEmptyBody     bool
+	requestLimit        int64
+	responseLimit       int64
+	retryMaxAttempts    int
+	retryBackoff        time.Duration
+	retryJitter         float64
+	retryJitterSet      bool
+	retryNonIdempotent  bool
+	maxRetryAfter       time.Duration
+	// retryRand is the random source for retry jitter, replaceable so tests are deterministic
+	retryRand          *rand.Rand
+	hedgeDelay         time.Duration
+	hedgeBackup        Doer
+	bodyFile           string
+	bodyBytes          []byte
+	bodyCached         bool
+	onUploadProgress   ProgressFunc
+	onDownloadProgress ProgressFunc
+	statusFunc         func(int) bool
+	statusErrorFunc    func(resp *http.Response) error
+	onRequest          []func(*http.Request) error
+	signer             func(req *http.Request, body []byte) error
+	dryRun             io.Writer
+	logger             Logger
+	transport          transportConfig
+	defaultClient      *http.Client
+	// err records a configuration error from a builder method, reported by Build
+	err error
 }
 
 func (b *RequestBuilder) Do(ctx context.Context, doer Doer, out interface{}) (*http.Response, error) {
-	req, err := b.Build(ctx)
+	_, resp, err := b.doLogged(ctx, doer, out, false)
+	return resp, err
+}
+
+// DoWithRaw behaves like Do but also returns the raw response body that was decoded into out. The body is read once
+// and decoded from the same buffer. The bytes are nil when nothing was decoded, such as with ExpectEmptyBody.
+func (b *RequestBuilder) DoWithRaw(ctx context.Context, doer Doer, out interface{}) ([]byte, *http.Response, error) {
+	return b.doLogged(ctx, doer, out, true)
+}
+
+func (b *RequestBuilder) doLogged(ctx context.Context, doer Doer, out interface{}, keepRaw bool) ([]byte, *http.Response, error) {
+	if b.logger == nil {
+		return b.do(ctx, doer, out, keepRaw)
+	}
+
+	start := time.Now()
+	raw, resp, err := b.do(ctx, doer, out, keepRaw)
+	b.logCompletion(resp, err, time.Since(start))
+	return raw, resp, err
+}
+
+func (b *RequestBuilder) do(ctx context.Context, doer Doer, out interface{}, keepRaw bool) ([]byte, *http.Response, error) {
+	resp, err := b.send(ctx, doer)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	// There is nothing to validate or decode in the synthetic response of a dry run
+	if b.dryRun != nil {
+		return nil, resp, nil
+	}
+
+	if errTarget, ok := b.errorTarget(resp.StatusCode); ok {
+		// The status is what the caller checks for, so a body that can't be decoded doesn't replace it
+		statusErr := newStatusError(resp)
+		_, statusErr.DecodeErr = b.unmarshalResponse(ctx, resp, errTarget, false)
+		return nil, nil, statusErr
+	}
+
+	err = b.validateStatusCode(resp)
+	if err != nil {
+		drainAndClose(resp.Body)
+		return nil, nil, err
+	}
+
+	// An expected redirect's body is typically empty or a placeholder page, so the caller only needs its headers
+	if isRedirect(resp.StatusCode) {
+		drainAndClose(resp.Body)
+		return nil, resp, nil
+	}
+
+	if b.enforceAccept {
+		err = b.validateAcceptedResponseType(resp)
+		if err != nil {
+			drainAndClose(resp.Body)
+			return nil, nil, err
+		}
+	}
+
+	if b.expectEmptyBody {
+		err = validateEmptyBody(ctx, resp)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, resp, nil
+	}
+
+	target, registered := b.decodeTargets[resp.StatusCode]
+	if !registered {
+		target = out
+	}
+	raw, err := b.unmarshalResponse(ctx, resp, target, keepRaw)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return raw, resp, nil
+}
+
+func isRedirect(status int) bool {
+	return status >= 300 && status < 400
+}
+
+// DoSwitch sends the request and decodes the response into the handler registered for the received status code,
+// returning the status that matched. Statuses without a handler are treated as unexpected.
+func (b *RequestBuilder) DoSwitch(ctx context.Context, doer Doer, handlers map[int]interface{}) (int, *http.Response, error) {
+	resp, err := b.send(ctx, doer)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// There is nothing to decode in the synthetic response of a dry run
+	if b.dryRun != nil {
+		return resp.StatusCode, resp, nil
+	}
+
+	out, ok := handlers[resp.StatusCode]
+	if !ok {
+		statusErr := newStatusErrorWithBody(resp)
+		drainAndClose(resp.Body)
+		return 0, nil, statusErr
+	}
+
+	_, err = b.unmarshalResponse(ctx, resp, out, false)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return resp.StatusCode, resp, nil
+}
+
+// DoStatus sends the request and returns the response status code without validating it or decoding the body,
+// which is discarded. It suits health checks and existence probes, where a 404 is an answer rather than an error.
+func (b *RequestBuilder) DoStatus(ctx context.Context, doer Doer) (int, error) {
+	resp, err := b.send(ctx, doer)
+	if err != nil {
+		return 0, err
+	}
+	drainAndClose(resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// AcceptedPostTypes issues an OPTIONS request to the builder's URL, resolved like Do resolves it, and returns the media
+// types advertised by the response's Accept-Post header. The builder's headers are sent with the request but its body
+// is not. A nil Doer uses the default Doer, as with Do.
+func (b *RequestBuilder) AcceptedPostTypes(ctx context.Context, doer Doer) ([]string, error) {
+	if doer == nil {
+		doer = b.defaultDoer()
+	} else if b.transport.strict() {
+		return nil, fmt.Errorf("minimum TLS version and transport timeouts only apply to the default Doer, but a Doer was provided")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodOptions, b.resolveURL(), http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create request")
+	}
+	if b.header != nil {
+		req.Header = b.header.Clone()
+		req.Header.Del(HeaderContentType)
 	}
 
 	resp, err := doer.Do(req)
 	if err != nil {
 		return nil, err
 	}
+	drainAndClose(resp.Body)
 
-	err = b.validateStatusCode(resp)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, newStatusError(resp)
+	}
+
+	var types []string
+	for _, val := range resp.Header.Values(HeaderAcceptPost) {
+		for _, mediaType := range strings.Split(val, ",") {
+			if mediaType = strings.TrimSpace(mediaType); mediaType != "" {
+				types = append(types, mediaType)
+			}
+		}
+	}
+
+	return types, nil
+}
+
+// send builds the request and hands it to the doer, retrying according to the builder's retry policy.
+func (b *RequestBuilder) send(ctx context.Context, doer Doer) (*http.Response, error) {
+	if b.dryRun != nil {
+		return b.sendDryRun(ctx)
+	}
+
+	if doer == nil {
+		doer = b.defaultDoer()
+	} else if b.transport.strict() {
+		return nil, fmt.Errorf("minimum TLS version and transport timeouts only apply to the default Doer, but a Doer was provided")
+	}
+
+	var resp *http.Response
+	var err error
+	if d, ok := requestTimeout(ctx); ok {
+		resp, err = b.sendWithTimeout(ctx, doer, d)
+	} else {
+		resp, err = b.sendAttempts(ctx, doer)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	err = b.unmarshalResponse(resp, out)
+	err = interceptResponse(resp)
 	if err != nil {
+		drainAndClose(resp.Body)
 		return nil, err
 	}
 
+	countBytesRead(ctx, resp)
 	return resp, nil
 }
 
+// sendAttempts sends the request once or, when a retry policy is configured, until it succeeds.
+func (b *RequestBuilder) sendAttempts(ctx context.Context, doer Doer) (*http.Response, error) {
+	if b.retryMaxAttempts <= 1 {
+		recordAttempt(ctx, 1)
+		return b.sendOnce(ctx, doer)
+	}
+
+	return b.sendWithRetry(ctx, doer)
+}
+
+// sendOnce makes a single attempt at the request, hedging against the backup doer when configured.
+func (b *RequestBuilder) sendOnce(ctx context.Context, doer Doer) (*http.Response, error) {
+	if b.hedgeBackup != nil {
+		return b.sendHedged(ctx, doer)
+	}
+
+	req, err := b.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return doer.Do(req)
+}
+
 func (b *RequestBuilder) Build(ctx context.Context) (*http.Request, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
 	var body io.Reader
 	var err error
 
@@ -73,31 +452,393 @@ func (b *RequestBuilder) Build(ctx context.Context) (*http.Request, error) {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, b.httpMethod, b.url, body)
+	direct, ok := b.directURL()
+	target := b.resolveURL()
+	if ok {
+		target = ""
+	}
+
+	req, err := http.NewRequestWithContext(ctx, b.httpMethod, target, body)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create request")
 	}
+	if ok {
+		req.URL = direct
+		req.Host = direct.Host
+	}
+
+	if b.bodyFile != "" {
+		// The file is streamed rather than held in memory, so there are no body bytes to sign
+		if b.signer != nil {
+			return nil, errSignFileBody
+		}
+		f, size, fileErr := b.openBodyFile()
+		if fileErr != nil {
+			return nil, fileErr
+		}
+		b.setFileBody(req, f, size)
+		// The file is only closed by sending the request, so a failure before that must close it here
+		defer func() {
+			if err != nil {
+				_ = f.Close()
+			}
+		}()
+	}
+
+	// Trailers can only follow a chunked body, so the length is left unknown to make the transport chunk it
+	if len(b.trailer) > 0 {
+		req.Trailer = b.trailer.Clone()
+		req.ContentLength = -1
+	}
+	if b.chunked && req.Body != nil && req.Body != http.NoBody {
+		req.TransferEncoding = []string{"chunked"}
+		req.ContentLength = -1
+	}
 
-	req.Header = b.header
+	// The request gets its own copy so that the client adding headers, such as cookies, doesn't leak into the builder
+	if b.header != nil {
+		req.Header = b.header.Clone()
+	}
+	if b.host != "" {
+		req.Host = b.host
+	}
+
+	if b.onUploadProgress != nil {
+		b.wrapUploadProgress(req)
+	}
+
+	for _, hook := range b.onRequest {
+		err = hook(req)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if b.signer != nil {
+		err = b.signer(req, b.bodyBytes)
+		if err != nil {
+			return nil, fmt.Errorf("unable to sign request: %v", err)
+		}
+	}
 
 	return req, nil
 }
 
+// OnRequest registers a hook that can adjust the request at the end of Build, so it applies to Do as well. Hooks run
+// in the order they were registered and an error from any of them fails the build.
+func (b *RequestBuilder) OnRequest(fn func(*http.Request) error) *RequestBuilder {
+	b.onRequest = append(b.onRequest, fn)
+	return b
+}
+
+// errSignFileBody is returned by Build when a request streaming its body from a file is also signed.
+var errSignFileBody = errors.New("unable to sign request: a body streamed with BodyFromFile can't be signed")
+
+// SignRequest registers a signer invoked as the last step of Build with the request and its raw body bytes, so it can
+// compute and set a signature header. body is nil when the request has no body. The request body is left unread.
+// Build fails when the body is streamed from a file with BodyFromFile, as its bytes aren't available to sign.
+func (b *RequestBuilder) SignRequest(fn func(req *http.Request, body []byte) error) *RequestBuilder {
+	b.signer = fn
+	return b
+}
+
+// Host overrides the host sent in the request, which Go takes from req.Host rather than the Host header. The
+// connection is still made to the host in the URL.
+func (b *RequestBuilder) Host(host string) *RequestBuilder {
+	b.host = host
+	return b
+}
+
+// PathParam sets the value substituted for the {name} placeholder in the URL. The value is path escaped.
+func (b *RequestBuilder) PathParam(name, value string) *RequestBuilder {
+	if b.pathParams == nil {
+		b.pathParams = map[string]string{}
+	}
+
+	b.pathParams[name] = value
+	return b
+}
+
+// directURL returns a copy of the URL given to NewURL when it can be sent without changes.
+func (b *RequestBuilder) directURL() (*url.URL, bool) {
+	if b.parsedURL == nil || len(b.pathParams) > 0 || len(b.query) > 0 || b.queryBody {
+		return nil, false
+	}
+	if b.baseURL != "" && !b.parsedURL.IsAbs() {
+		return nil, false
+	}
+	return cloneURL(b.parsedURL), true
+}
+
+// applyBaseURL joins a relative URL onto the builder's base URL, if it has one.
+func (b *RequestBuilder) applyBaseURL(resolved string) string {
+	if b.baseURL == "" {
+		return resolved
+	}
+
+	// An unparseable URL is returned as-is so that building the request reports it
+	u, err := url.Parse(resolved)
+	if err != nil || u.IsAbs() {
+		return resolved
+	}
+	return strings.TrimSuffix(b.baseURL, "/") + "/" + strings.TrimPrefix(resolved, "/")
+}
+
+func (b *RequestBuilder) resolveURL() string {
+	resolved := b.url
+	for name, value := range b.pathParams {
+		resolved = strings.ReplaceAll(resolved, "{"+name+"}", url.PathEscape(value))
+	}
+	resolved = b.applyBaseURL(resolved)
+
+	// A body that can't be encoded as a query is reported by Build
+	bodyQuery, _ := b.bodyQuery()
+	if len(b.query) == 0 && len(bodyQuery) == 0 {
+		return resolved
+	}
+
+	// An unparseable URL is returned as-is so that building the request reports it
+	u, err := url.Parse(resolved)
+	if err != nil {
+		return resolved
+	}
+
+	// Only the query is rewritten, so the fragment and everything else in the URL is kept as written
+	query := u.Query()
+	for _, values := range []url.Values{b.query, bodyQuery} {
+		for key, vals := range values {
+			for _, val := range vals {
+				query.Add(key, val)
+			}
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String()
+}
+
+// ResolveURL returns the URL the request will be sent to, with path parameters substituted and query parameters
+// applied, without building the request.
+func (b *RequestBuilder) ResolveURL() (string, error) {
+	if b.err != nil {
+		return "", b.err
+	}
+	_, err := b.bodyQuery()
+	if err != nil {
+		return "", err
+	}
+
+	resolved := b.resolveURL()
+	u, err := url.Parse(resolved)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse url: %v", err)
+	}
+
+	return u.String(), nil
+}
+
+// Query adds a query parameter to the URL, keeping any parameters already present in it.
+func (b *RequestBuilder) Query(key, value string) *RequestBuilder {
+	if b.query == nil {
+		b.query = url.Values{}
+	}
+
+	b.query.Add(key, value)
+	return b
+}
+
 func (b *RequestBuilder) ContentType(contentType string) *RequestBuilder {
 	b.contentType = contentType
+	b.contentTypeSet = true
+	b.invalidateBody()
 	return b
 }
 
+// BodyMarshaler is implemented by bodies that serialize themselves. A body implementing it is sent as the bytes and
+// content type it returns, taking precedence over the builder's content type and encoder.
+type BodyMarshaler interface {
+	MarshalBody() (contentType string, data []byte, err error)
+}
+
+// Encoder marshals the body with fn instead of the built-in encoders and sends it with the given content type.
+func (b *RequestBuilder) Encoder(contentType string, fn func(interface{}) ([]byte, error)) *RequestBuilder {
+	b.contentType = contentType
+	b.contentTypeSet = true
+	b.encoder = fn
+	b.invalidateBody()
+	return b
+}
+
+// DecodeWith decodes responses of the given media type with fn instead of the built-in decoders.
+func (b *RequestBuilder) DecodeWith(mediaType string, fn func([]byte, interface{}) error) *RequestBuilder {
+	if b.decoders == nil {
+		b.decoders = map[string]func([]byte, interface{}) error{}
+	}
+
+	b.decoders[mediaType] = fn
+	return b
+}
+
+// DecodePath decodes only the part of a JSON response found at the dot-separated path of object keys, for example
+// "data.user" for a payload wrapped as {"data": {"user": {...}}}.
+func (b *RequestBuilder) DecodePath(path string) *RequestBuilder {
+	b.decodePath = path
+	return b
+}
+
+func (b *RequestBuilder) Body(body interface{}) *RequestBuilder {
+	b.body = body
+	b.bodyFile = ""
+	b.invalidateBody()
+	return b
+}
+
+// invalidateBody discards the marshalled body along with the headers describing it, which are set again for the new
+// body when the request is next built. A nil body is sent without them.
+func (b *RequestBuilder) invalidateBody() {
+	b.bodyBytes = nil
+	b.bodyCached = false
+	b.header.Del(HeaderContentType)
+	b.header.Del(HeaderContentEncoding)
+}
+
+// StatusIs sets the only expected status code. When a 3xx status is expected, Do returns the response without decoding
+// its body so that headers like Location can be read, which requires a Doer that doesn't follow redirects.
 func (b *RequestBuilder) StatusIs(status int) *RequestBuilder {
 	b.expectedStatusCodes = []int{status}
+	b.expectedStatusSet = true
 	return b
 }
 
 func (b *RequestBuilder) StatusIn(statuses []int) *RequestBuilder {
 	b.expectedStatusCodes = statuses
+	b.expectedStatusSet = true
+	return b
+}
+
+// StatusFunc accepts any status code for which fn returns true, in addition to codes listed with StatusIs or StatusIn.
+func (b *RequestBuilder) StatusFunc(fn func(int) bool) *RequestBuilder {
+	b.statusFunc = fn
+	return b
+}
+
+// StatusErrorFunc overrides how an unexpected status code becomes an error. If fn returns nil the response is treated
+// as successful and decoded as usual.
+func (b *RequestBuilder) StatusErrorFunc(fn func(resp *http.Response) error) *RequestBuilder {
+	b.statusErrorFunc = fn
+	return b
+}
+
+// ErrorStatusIn decodes the body of responses with one of the given status codes into errOut, after which Do returns a
+// StatusError. Responses with other statuses are validated and decoded into out as usual, so the error statuses
+// don't need to be listed with StatusIs or StatusIn. It's shorthand for calling DecodeInto for each status.
+func (b *RequestBuilder) ErrorStatusIn(statuses []int, errOut interface{}) *RequestBuilder {
+	for _, status := range statuses {
+		b.DecodeInto(status, errOut)
+	}
 	return b
 }
 
+// DecodeInto registers target as the value Do decodes the response body into when the response has the given status,
+// instead of out. It can be called once per status. When the status isn't an expected one, Do still returns a
+// StatusError after decoding the body into target, so error payloads can be captured per status.
+func (b *RequestBuilder) DecodeInto(status int, target interface{}) *RequestBuilder {
+	if b.decodeTargets == nil {
+		b.decodeTargets = map[int]interface{}{}
+	}
+	b.decodeTargets[status] = target
+	return b
+}
+
+// DecodeErrors decodes the body of any 4xx or 5xx response into target, after which Do returns a StatusError, for
+// APIs with one error type shared by all endpoints. Unless StatusIs or StatusIn say otherwise, every 2xx status is
+// then treated as a success. Targets registered with DecodeInto or ErrorStatusIn take precedence.
+func (b *RequestBuilder) DecodeErrors(target interface{}) *RequestBuilder {
+	b.errorOut = target
+	return b
+}
+
+// errorTarget returns the value the body of a response with an unexpected status is decoded into, if there is one.
+func (b *RequestBuilder) errorTarget(status int) (interface{}, bool) {
+	if b.isExpectedStatus(status) {
+		return nil, false
+	}
+	if target, ok := b.decodeTargets[status]; ok {
+		return target, true
+	}
+	if b.errorOut != nil && status >= 400 {
+		return b.errorOut, true
+	}
+	return nil, false
+}
+
+// Accept sets the Accept header to the given media types.
+func (b *RequestBuilder) Accept(mediaTypes ...string) *RequestBuilder {
+	return b.SetHeader(HeaderAccept, strings.Join(mediaTypes, ", "))
+}
+
+// EnforceAcceptedResponseType makes Do fail when the response Content-Type isn't one of the media types listed in the
+// request's Accept header, catching servers that ignore content negotiation.
+func (b *RequestBuilder) EnforceAcceptedResponseType() *RequestBuilder {
+	b.enforceAccept = true
+	return b
+}
+
+// ExpectEmptyBody makes Do fail if the response has a non-empty body. The response is not decoded.
+func (b *RequestBuilder) ExpectEmptyBody() *RequestBuilder {
+	b.expectEmptyBody = true
+	return b
+}
+
+// IfMatch makes the request conditional on the resource still having the given ETag. If it doesn't, Do fails with an
+// error matching ErrPreconditionFailed.
+func (b *RequestBuilder) IfMatch(etag string) *RequestBuilder {
+	return b.SetHeader(HeaderIfMatch, etag)
+}
+
+// ETag returns the ETag header of a response, for use with IfMatch.
+func ETag(resp *http.Response) string {
+	return resp.Header.Get(HeaderETag)
+}
+
+func (b *RequestBuilder) BearerToken(token string) *RequestBuilder {
+	return b.SetHeader(HeaderAuthorization, "Bearer "+token)
+}
+
+func (b *RequestBuilder) BasicAuth(username, password string) *RequestBuilder {
+	credentials := base64.StdEncoding.EncodeToString([]byte(username + ":" + password))
+	return b.SetHeader(HeaderAuthorization, "Basic "+credentials)
+}
+
+// UserAgent sets the User-Agent header.
+func (b *RequestBuilder) UserAgent(userAgent string) *RequestBuilder {
+	return b.SetHeader(HeaderUserAgent, userAgent)
+}
+
+// Referer sets the Referer header. An invalid or relative URL is reported by Build.
+func (b *RequestBuilder) Referer(referer string) *RequestBuilder {
+	return b.setURLHeader(HeaderReferer, referer)
+}
+
+// Origin sets the Origin header. An invalid or relative URL is reported by Build.
+func (b *RequestBuilder) Origin(origin string) *RequestBuilder {
+	return b.setURLHeader(HeaderOrigin, origin)
+}
+
+func (b *RequestBuilder) setURLHeader(key, value string) *RequestBuilder {
+	u, err := url.Parse(value)
+	if err != nil {
+		b.err = fmt.Errorf("invalid %s url: %v", key, err)
+		return b
+	}
+	if !u.IsAbs() || u.Host == "" {
+		b.err = fmt.Errorf("invalid %s url: must be absolute: %q", key, value)
+		return b
+	}
+
+	return b.SetHeader(key, value)
+}
+
 func (b *RequestBuilder) AddHeader(key, value string) *RequestBuilder {
 	if b.header == nil {
 		b.header = http.Header{}
@@ -107,6 +848,24 @@ func (b *RequestBuilder) AddHeader(key, value string) *RequestBuilder {
 	return b
 }
 
+// AddTrailer adds an HTTP trailer, sent after the request body. Declaring trailers makes the body chunked, and they're
+// only sent when the request has a body.
+func (b *RequestBuilder) AddTrailer(key, value string) *RequestBuilder {
+	if b.trailer == nil {
+		b.trailer = http.Header{}
+	}
+
+	b.trailer.Add(key, value)
+	return b
+}
+
+// Chunked sends the request body with chunked transfer encoding instead of a Content-Length, for APIs that expect
+// the framing of a streamed upload. It has no effect on requests without a body.
+func (b *RequestBuilder) Chunked() *RequestBuilder {
+	b.chunked = true
+	return b
+}
+
 func (b *RequestBuilder) SetHeader(key, value string) *RequestBuilder {
 	if b.header == nil {
 		b.header = http.Header{}
@@ -116,11 +875,105 @@ func (b *RequestBuilder) SetHeader(key, value string) *RequestBuilder {
 	return b
 }
 
+// SetRawHeader sets a header under exactly the given key, without canonicalizing it, for legacy servers that expect
+// casing like X-foo. The HTTP/1.1 transport writes the key as-is, but HTTP/2 lowercases every header name. Reading the
+// header back with Header.Get won't find it, and setting the canonical form as well sends both.
+func (b *RequestBuilder) SetRawHeader(key, value string) *RequestBuilder {
+	if b.header == nil {
+		b.header = http.Header{}
+	}
+
+	b.header[key] = []string{value}
+	return b
+}
+
+// OnUploadProgress registers a callback invoked as the request body is consumed by the transport. totalBytes is the
+// body length when it is known up front and -1 otherwise.
+func (b *RequestBuilder) OnUploadProgress(fn func(bytesSent, totalBytes int64)) *RequestBuilder {
+	b.onUploadProgress = fn
+	return b
+}
+
+// OnDownloadProgress registers a callback invoked as the response body is read through DoStream or DoDownload.
+// totalBytes is the response Content-Length, or -1 when the server didn't provide one.
+func (b *RequestBuilder) OnDownloadProgress(fn func(bytesRead, totalBytes int64)) *RequestBuilder {
+	b.onDownloadProgress = fn
+	return b
+}
+
+func (b *RequestBuilder) wrapUploadProgress(req *http.Request) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return
+	}
+
+	total := req.ContentLength
+	if total <= 0 {
+		total = -1
+	}
+
+	req.Body = newProgressReadCloser(req.Body, total, b.onUploadProgress)
+	if getBody := req.GetBody; getBody != nil {
+		req.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return newProgressReadCloser(body, total, b.onUploadProgress), nil
+		}
+	}
+}
+
 func (b *RequestBuilder) resolveContentType() (body io.Reader, err error) {
-	if b.body == nil {
+	if b.queryBody {
+		_, err = b.bodyQuery()
+		if err != nil {
+			return nil, err
+		}
 		return http.NoBody, nil
 	}
 
+	if b.body == nil && len(b.formParts) == 0 {
+		return http.NoBody, nil
+	}
+
+	// The marshalled body is cached so that repeated calls to Build reuse the same bytes with a fresh reader
+	if !b.bodyCached {
+		b.bodyBytes, err = b.marshalBody()
+		if err != nil {
+			return nil, err
+		}
+		if b.contentEncoding != "" {
+			b.bodyBytes, err = compressBody(b.contentEncoding, b.bodyBytes)
+			if err != nil {
+				return nil, err
+			}
+			b.SetHeader(HeaderContentEncoding, b.contentEncoding)
+		}
+		b.bodyCached = true
+	}
+
+	err = b.checkRequestLimit(int64(len(b.bodyBytes)))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b.bodyBytes), nil
+}
+
+func (b *RequestBuilder) marshalBody() (bodyBytes []byte, err error) {
+	if len(b.formParts) > 0 {
+		return b.marshalMultipart()
+	}
+
+	if marshaler, ok := b.body.(BodyMarshaler); ok {
+		contentType, data, err := marshaler.MarshalBody()
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal body: %v", err)
+		}
+		b.SetHeader(HeaderContentType, contentType)
+		return data, nil
+	}
+
 	b.SetHeader(HeaderContentType, b.contentType)
 
 	// Parse the content type using mime parsing and save the mediatype as the content type
@@ -130,31 +983,145 @@ func (b *RequestBuilder) resolveContentType() (body io.Reader, err error) {
 		return nil, fmt.Errorf("unable to parse media type: %v", err)
 	}
 
-	var bodyBytes []byte
+	if b.encoder != nil {
+		bodyBytes, err = b.encoder(b.body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal body to %s: %v", b.contentType, err)
+		}
+		return bodyBytes, nil
+	}
+
 	switch b.contentType {
-	case MIMEApplicationJson:
+	case MIMEApplicationJson, MIMEMergePatchJson:
 		bodyBytes, err = json.Marshal(b.body)
 		if err != nil {
 			return nil, fmt.Errorf("unable to marshal body to json: %v", err)
 		}
-		body = bytes.NewReader(bodyBytes)
+	case MIMEApplicationNDJSON:
+		bodyBytes, err = marshalNDJSON(b.body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal body to ndjson: %v", err)
+		}
 	case MIMEApplicationXml, MIMETextXml:
 		bodyBytes, err = xml.Marshal(b.body)
 		if err != nil {
 			return nil, fmt.Errorf("unable to marshal body to xml: %v", err)
 		}
-		body = bytes.NewReader(bodyBytes)
+	case MIMETextCsv:
+		bodyBytes, err = marshalCSV(b.body)
+		if err != nil {
+			return nil, fmt.Errorf("unable to marshal body to csv: %v", err)
+		}
 	default:
 		return nil, fmt.Errorf("unsupported content type: %s", b.contentType)
 	}
 
-	return body, nil
+	return bodyBytes, nil
 }
 
-func (b *RequestBuilder) unmarshalResponse(resp *http.Response, out interface{}) error {
-	respBytes, err := ioutil.ReadAll(resp.Body)
-	switch b.contentType {
-	case MIMEApplicationJson:
+// marshalCSV writes a [][]string body as CSV records, one per row.
+func marshalCSV(body interface{}) ([]byte, error) {
+	rows, ok := body.([][]string)
+	if !ok {
+		return nil, fmt.Errorf("expected a [][]string body, got %T", body)
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	err := w.WriteAll(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// marshalNDJSON marshals each element of a slice or array body as JSON on its own newline-terminated line.
+func marshalNDJSON(body interface{}) ([]byte, error) {
+	v := reflect.ValueOf(body)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		return nil, fmt.Errorf("expected a slice or array body, got %s", v.Kind())
+	}
+
+	var buf bytes.Buffer
+	for i := 0; i < v.Len(); i++ {
+		line, err := json.Marshal(v.Index(i).Interface())
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	return buf.Bytes(), nil
+}
+
+// unmarshalResponse reads and decodes the response body into out. The body is read into a pooled buffer, so the bytes
+// it decoded are only returned, as a copy, when keepRaw is set.
+func (b *RequestBuilder) unmarshalResponse(ctx context.Context, resp *http.Response, out interface{}, keepRaw bool) ([]byte, error) {
+	buf := getBodyBuffer()
+	err := b.readResponseBody(ctx, resp, buf)
+	if err != nil {
+		// The read may still be writing to the buffer if the context is done, so it isn't returned to the pool
+		return nil, err
+	}
+
+	respBytes, err := decompressBody(resp.Header.Get(HeaderContentEncoding), buf.Bytes(), b.responseLimit)
+	if err != nil {
+		putBodyBuffer(buf)
+		return nil, err
+	}
+
+	contentType, err := b.responseContentType(resp, respBytes)
+	if err != nil {
+		putBodyBuffer(buf)
+		return nil, err
+	}
+
+	// The built-in decoders copy whatever they keep, but a custom decoder might hold on to the bytes it was given
+	if _, custom := b.decoders[contentType]; !custom {
+		defer putBodyBuffer(buf)
+	}
+
+	err = b.decode(contentType, respBytes, out)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(b.requiredFields) > 0 {
+		err = b.validateRequiredFields(contentType, respBytes)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !keepRaw {
+		return nil, nil
+	}
+	return append([]byte(nil), respBytes...), nil
+}
+
+func (b *RequestBuilder) decode(contentType string, respBytes []byte, out interface{}) (err error) {
+	if b.decodePath != "" {
+		if !isJSONContentType(contentType) {
+			return fmt.Errorf("decode path is only supported for json, got %s", contentType)
+		}
+		respBytes, err = extractJSONPath(respBytes, b.decodePath)
+		if err != nil {
+			return err
+		}
+	}
+
+	if decode, ok := b.decoders[contentType]; ok {
+		err = decode(respBytes, out)
+		if err != nil {
+			return fmt.Errorf("unable to unmarshal %s body: %v", contentType, err)
+		}
+		return nil
+	}
+
+	switch contentType {
+	case MIMEApplicationJson, MIMEApplicationProblemJson, MIMEMergePatchJson:
 		err = json.Unmarshal(respBytes, &out)
 		if err != nil {
 			return fmt.Errorf("unable to unmarshal json body: %v", err)
@@ -165,31 +1132,229 @@ func (b *RequestBuilder) unmarshalResponse(resp *http.Response, out interface{})
 			return fmt.Errorf("unable to unmarshal xml body: %v", err)
 		}
 	default:
-		return fmt.Errorf("unsupported content type: %s", b.contentType)
+		return fmt.Errorf("unsupported content type: %s", contentType)
 	}
 
 	return nil
 }
 
-func (b *RequestBuilder) validateStatusCode(resp *http.Response) error {
-	if len(b.expectedStatusCodes) == 0 {
-		b.expectedStatusCodes = []int{http.StatusOK}
+func isJSONContentType(contentType string) bool {
+	switch contentType {
+	case MIMEApplicationJson, MIMEApplicationProblemJson, MIMEMergePatchJson:
+		return true
+	}
+	return false
+}
+
+// extractJSONPath returns the raw JSON found by following the dot-separated object keys in path.
+func extractJSONPath(data []byte, path string) ([]byte, error) {
+	for _, key := range strings.Split(path, ".") {
+		var obj map[string]json.RawMessage
+		err := json.Unmarshal(data, &obj)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode path %s: %v", path, err)
+		}
+
+		var ok bool
+		data, ok = obj[key]
+		if !ok {
+			return nil, fmt.Errorf("unable to decode path %s: key %q not found", path, key)
+		}
+	}
+
+	return data, nil
+}
+
+// responseContentType returns the media type the response body should be decoded as. The response's Content-Type is
+// used when it's a media type there's a decoder for, and the request content type otherwise, so that JSON served as
+// something like application/hal+json or text/plain still decodes. A body without a Content-Type is sniffed.
+func (b *RequestBuilder) responseContentType(resp *http.Response, respBytes []byte) (string, error) {
+	requestType, _, err := mime.ParseMediaType(b.contentType)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse media type: %v", err)
+	}
+
+	header := resp.Header.Get(HeaderContentType)
+	if header == "" {
+		if sniffed := sniffContentType(respBytes); sniffed != "" {
+			return sniffed, nil
+		}
+		return requestType, nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse response media type: %v", err)
+	}
+
+	if b.canDecode(contentType) {
+		return contentType, nil
 	}
+	return requestType, nil
+}
+
+// canDecode reports whether decode supports the media type.
+func (b *RequestBuilder) canDecode(contentType string) bool {
+	if _, ok := b.decoders[contentType]; ok {
+		return true
+	}
+
+	switch contentType {
+	case MIMEApplicationJson, MIMEApplicationProblemJson, MIMEMergePatchJson, MIMEApplicationXml, MIMETextXml:
+		return true
+	}
+	return false
+}
+
+// sniffContentType guesses whether a body without a Content-Type is JSON or XML from its first non-whitespace byte,
+// returning an empty string when it can't tell.
+func sniffContentType(respBytes []byte) string {
+	trimmed := bytes.TrimLeft(respBytes, " \t\r\n")
+	if len(trimmed) == 0 {
+		return ""
+	}
+
+	switch trimmed[0] {
+	case '{', '[':
+		return MIMEApplicationJson
+	case '<':
+		return MIMEApplicationXml
+	default:
+		return ""
+	}
+}
+
+// maxDrainBytes caps how much of an unwanted response body is read before closing it. Reading the body to EOF lets
+// the transport reuse the connection, but past this point it's cheaper to let the connection go.
+const maxDrainBytes = 64 << 10
+
+// drainAndClose discards what's left of a response body that won't be decoded and closes it.
+func drainAndClose(body io.ReadCloser) {
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(body, maxDrainBytes))
+	_ = body.Close()
+}
 
-	var isExpectedStatus bool
-	for _, code := range b.expectedStatusCodes {
-		if isExpectedStatus = resp.StatusCode == code; isExpectedStatus {
-			break
+func validateEmptyBody(ctx context.Context, resp *http.Response) error {
+	var buf bytes.Buffer
+	err := readBody(ctx, resp.Body, &buf)
+	if err != nil {
+		return err
+	}
+
+	if buf.Len() > 0 {
+		return fmt.Errorf("expected an empty response body, received %d bytes", buf.Len())
+	}
+
+	return nil
+}
+
+// readBody reads the body to completion into buf and closes it, giving up as soon as the context is done. Not every
+// Doer wires the request context into the response body, so a server stalling mid-body could otherwise block the read
+// indefinitely. When the context is done the abandoned read may still write to buf, so buf must not be reused.
+func readBody(ctx context.Context, body io.ReadCloser, buf *bytes.Buffer) error {
+	done := make(chan error, 1)
+	go func() {
+		_, err := buf.ReadFrom(body)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		_ = body.Close()
+		if err != nil {
+			return fmt.Errorf("unable to read response body: %w", err)
 		}
+		return nil
+	case <-ctx.Done():
+		// Closing the body unblocks the pending read so the goroutine can exit
+		_ = body.Close()
+		return fmt.Errorf("unable to read response body: %w", ctx.Err())
 	}
+}
 
-	if !isExpectedStatus {
-		return fmt.Errorf("received unexpected status code: %v", resp.StatusCode)
+func (b *RequestBuilder) validateAcceptedResponseType(resp *http.Response) error {
+	accepted := b.header.Values(HeaderAccept)
+	if len(accepted) == 0 {
+		return nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(resp.Header.Get(HeaderContentType))
+	if err != nil {
+		return fmt.Errorf("unable to parse response media type: %v", err)
+	}
+
+	for _, val := range accepted {
+		for _, acceptedType := range strings.Split(val, ",") {
+			acceptedType, _, err = mime.ParseMediaType(strings.TrimSpace(acceptedType))
+			if err != nil {
+				continue
+			}
+			if mediaTypeMatches(acceptedType, contentType) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("response content type %s is not accepted: %s", contentType, strings.Join(accepted, ", "))
+}
+
+// mediaTypeMatches reports whether contentType satisfies the pattern, which may use */* or type/* wildcards.
+func mediaTypeMatches(pattern, contentType string) bool {
+	if pattern == "*/*" || pattern == contentType {
+		return true
+	}
+
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.HasPrefix(contentType, strings.TrimSuffix(pattern, "*"))
+	}
+
+	return false
+}
+
+func (b *RequestBuilder) validateStatusCode(resp *http.Response) error {
+	if !b.isExpectedStatus(resp.StatusCode) {
+		if b.statusErrorFunc != nil {
+			return b.statusErrorFunc(resp)
+		}
+		return newStatusErrorWithBody(resp)
 	}
 
 	return nil
 }
 
+func (b *RequestBuilder) isExpectedStatus(status int) bool {
+	if b.statusFunc != nil && b.statusFunc(status) {
+		return true
+	}
+
+	// Decoding errors by range implies a success range, unless the expected statuses are listed explicitly
+	if b.errorOut != nil && b.statusFunc == nil && !b.expectedStatusSet {
+		return status >= 200 && status < 300
+	}
+
+	// With a status predicate configured, only explicitly listed codes are accepted alongside it
+	expectedStatusCodes := b.expectedStatusCodes
+	if b.statusFunc != nil && !b.expectedStatusSet {
+		expectedStatusCodes = nil
+	} else if len(expectedStatusCodes) == 0 {
+		expectedStatusCodes = []int{http.StatusOK}
+	}
+
+	for _, code := range expectedStatusCodes {
+		if status == code {
+			return true
+		}
+	}
+	return false
+}
+
 type Doer interface {
 	Do(*http.Request) (*http.Response, error)
 }
+
+// DoerFunc adapts an ordinary function to the Doer interface.
+type DoerFunc func(*http.Request) (*http.Response, error)
+
+func (f DoerFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -1,13 +1,25 @@
 package httprequest
 
 import (
+	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jackramey/httprequest/httpmock"
 	"github.com/stretchr/testify/assert"
@@ -89,6 +101,108 @@ func TestRequestBuilder_Build(t *testing.T) {
 		require.NoError(t, err)
 		assert.Equal(t, expectedBytes, bodyBytes)
 	})
+	t.Run("Building twice reuses the marshalled body", func(t *testing.T) {
+		expectedBytes, err := json.Marshal(req1)
+		require.NoError(t, err)
+
+		builder := New(http.MethodPost, testUrl, req1)
+		for i := 0; i < 2; i++ {
+			req, err := builder.Build(context.Background())
+			require.NoError(t, err)
+			bodyBytes, err := ioutil.ReadAll(req.Body)
+			require.NoError(t, err)
+			assert.Equal(t, expectedBytes, bodyBytes)
+			assert.Equal(t, int64(len(expectedBytes)), req.ContentLength)
+		}
+	})
+	t.Run("Changing the body or content type invalidates the cached body", func(t *testing.T) {
+		builder := New(http.MethodPost, testUrl, req1)
+		_, err := builder.Build(context.Background())
+		require.NoError(t, err)
+
+		xmlBytes, err := xml.Marshal(req1)
+		require.NoError(t, err)
+		req, err := builder.ContentType(MIMEApplicationXml).Build(context.Background())
+		require.NoError(t, err)
+		bodyBytes, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, xmlBytes, bodyBytes)
+
+		other := UserRequest{ID: 7, Name: "sam"}
+		otherBytes, err := xml.Marshal(other)
+		require.NoError(t, err)
+		req, err = builder.Body(other).Build(context.Background())
+		require.NoError(t, err)
+		bodyBytes, err = ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, otherBytes, bodyBytes)
+	})
+	t.Run("Custom encoder is used for the body and content type", func(t *testing.T) {
+		encodeCSV := func(v interface{}) ([]byte, error) {
+			var buf bytes.Buffer
+			w := csv.NewWriter(&buf)
+			for _, user := range v.([]UserRequest) {
+				err := w.Write([]string{strconv.Itoa(user.ID), user.Name, strconv.FormatBool(user.IsAdmin)})
+				if err != nil {
+					return nil, err
+				}
+			}
+			w.Flush()
+			return buf.Bytes(), w.Error()
+		}
+
+		req, err := New(http.MethodPost, testUrl, []UserRequest{req1, {ID: 7, Name: "sam"}}).
+			Encoder("text/csv", encodeCSV).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "text/csv", req.Header.Get(HeaderContentType))
+
+		bodyBytes, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "6,jack,true\n7,sam,false\n", string(bodyBytes))
+	})
+	t.Run("NDJSON content type marshals one line per element", func(t *testing.T) {
+		users := []UserRequest{req1, {ID: 7, Name: "sam"}, {ID: 8, Name: "alex", IsAdmin: true}}
+		req, err := New(http.MethodPost, testUrl, users).
+			ContentType(MIMEApplicationNDJSON).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MIMEApplicationNDJSON, req.Header.Get(HeaderContentType))
+
+		bodyBytes, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSuffix(string(bodyBytes), "\n"), "\n")
+		require.Len(t, lines, 3)
+		for i, line := range lines {
+			var user UserRequest
+			require.NoError(t, json.Unmarshal([]byte(line), &user))
+			assert.Equal(t, users[i], user)
+		}
+	})
+	t.Run("NDJSON content type with a non-slice body returns an error", func(t *testing.T) {
+		_, err := New(http.MethodPost, testUrl, req1).
+			ContentType(MIMEApplicationNDJSON).
+			Build(context.Background())
+		require.Error(t, err)
+	})
+	t.Run("CSV content type writes one record per row", func(t *testing.T) {
+		rows := [][]string{{"id", "name"}, {"6", "jack, jr"}}
+		req, err := New(http.MethodPost, testUrl, rows).
+			ContentType(MIMETextCsv).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MIMETextCsv, req.Header.Get(HeaderContentType))
+
+		bodyBytes, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "id,name\n6,\"jack, jr\"\n", string(bodyBytes))
+	})
+	t.Run("CSV content type with a non-[][]string body returns an error", func(t *testing.T) {
+		_, err := New(http.MethodPost, testUrl, req1).
+			ContentType(MIMETextCsv).
+			Build(context.Background())
+		require.Error(t, err)
+	})
 	t.Run("Builder with invalid content type returns an error", func(t *testing.T) {
 		_, err := New(http.MethodGet, testUrl, req1).
 			ContentType("application/morse-code").
@@ -97,6 +211,145 @@ func TestRequestBuilder_Build(t *testing.T) {
 	})
 }
 
+func TestRequestBuilder_Do_csvBody(t *testing.T) {
+	rows := [][]string{{"id", "name"}, {"6", "jack"}, {"7", "sam"}}
+	mock := httpmock.NewMock()
+	mock.POST(testUrl, rows).WithContentType(MIMETextCsv).Return(http.StatusOK, resp1, nil)
+
+	var out UserResponse
+	_, err := New(http.MethodPost, testUrl, rows).
+		ContentType(MIMETextCsv).
+		Do(context.Background(), mock, &out)
+	require.NoError(t, err)
+	assert.Equal(t, resp1, out)
+	mock.AssertExpectations(t)
+}
+
+// protoUser stands in for a type with its own wire format, such as a generated protobuf message.
+type protoUser struct {
+	name string
+	err  error
+}
+
+func (u protoUser) MarshalBody() (string, []byte, error) {
+	return "application/x-protobuf", []byte("\x0a\x04" + u.name), u.err
+}
+
+func TestRequestBuilder_BodyMarshaler(t *testing.T) {
+	t.Run("Marshaler output is sent as-is", func(t *testing.T) {
+		builder := New(http.MethodPost, testUrl, protoUser{name: "jack"}).ContentType(MIMEApplicationXml)
+		require.NoError(t, builder.Validate())
+
+		req, err := builder.Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "application/x-protobuf", req.Header.Get(HeaderContentType))
+
+		body, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, []byte("\x0a\x04jack"), body)
+	})
+	t.Run("Marshaler error is returned", func(t *testing.T) {
+		_, err := New(http.MethodPost, testUrl, protoUser{err: errors.New("missing field")}).
+			Build(context.Background())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "missing field")
+	})
+}
+
+func TestRequestBuilder_PathParam(t *testing.T) {
+	req, err := New(http.MethodGet, "https://example.com/api/v1/files/{name}", nil).
+		PathParam("name", "a b/c").
+		Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/api/v1/files/a%20b%2Fc", req.URL.String())
+}
+
+func TestRequestBuilder_ResolveURL(t *testing.T) {
+	t.Run("Path params and query are applied to the base URL", func(t *testing.T) {
+		resolved, err := New(http.MethodGet, "https://example.com/api/v1/users/{id}/files?sort=name", nil).
+			PathParam("id", "42").
+			Query("type", "image/png").
+			Query("page", "2").
+			ResolveURL()
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/api/v1/users/42/files?page=2&sort=name&type=image%2Fpng", resolved)
+	})
+	t.Run("Fragment survives added query params", func(t *testing.T) {
+		resolved, err := New(http.MethodGet, "https://example.com/docs/{page}?lang=en#section-2", nil).
+			PathParam("page", "setup").
+			Query("version", "3").
+			ResolveURL()
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/docs/setup?lang=en&version=3#section-2", resolved)
+
+		req, err := New(http.MethodGet, "https://example.com/docs?lang=en#section-2", nil).
+			Query("version", "3").
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "section-2", req.URL.Fragment)
+	})
+	t.Run("Invalid URL returns an error", func(t *testing.T) {
+		_, err := New(http.MethodGet, "https://example.com/%zz", nil).ResolveURL()
+		require.Error(t, err)
+	})
+}
+
+func TestRequestBuilder_Host(t *testing.T) {
+	req, err := New(http.MethodGet, testUrl, nil).
+		Host("internal.example.com").
+		Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "internal.example.com", req.Host)
+	assert.Equal(t, "example.com", req.URL.Host)
+}
+
+func TestRequestBuilder_OnRequest(t *testing.T) {
+	t.Run("Hook modifies the built request", func(t *testing.T) {
+		req, err := New(http.MethodGet, testUrl, nil).
+			OnRequest(func(req *http.Request) error {
+				req.Close = true
+				return nil
+			}).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.True(t, req.Close)
+	})
+	t.Run("Hook error fails the build", func(t *testing.T) {
+		hookErr := errors.New("hook failed")
+		_, err := New(http.MethodGet, testUrl, nil).
+			OnRequest(func(req *http.Request) error {
+				return hookErr
+			}).
+			Build(context.Background())
+		assert.Equal(t, hookErr, err)
+	})
+}
+
+func TestRequestBuilder_SignRequest(t *testing.T) {
+	key := []byte("secret")
+	sign := func(data []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(data)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	expectedBytes, err := json.Marshal(req1)
+	require.NoError(t, err)
+
+	req, err := New(http.MethodPost, testUrl, req1).
+		SignRequest(func(req *http.Request, body []byte) error {
+			req.Header.Set("X-Signature", sign(append([]byte(req.Method+" "+req.URL.Path+"\n"), body...)))
+			return nil
+		}).
+		Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, sign(append([]byte("POST /api/v1/endpoint\n"), expectedBytes...)), req.Header.Get("X-Signature"))
+
+	bodyBytes, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, expectedBytes, bodyBytes)
+}
+
 func TestRequestBuilder_validateStatusCode(t *testing.T) {
 	tests := []struct {
 		name                string
@@ -139,6 +392,56 @@ func TestRequestBuilder_validateStatusCode(t *testing.T) {
 	}
 }
 
+func TestRequestBuilder_StatusFunc(t *testing.T) {
+	successOrRedirect := func(status int) bool {
+		return status >= 200 && status < 400
+	}
+
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		status  int
+		wantErr assert.ErrorAssertionFunc
+	}{
+		{
+			name:    "Predicate accepts a 2xx status",
+			builder: New(http.MethodGet, testUrl, nil).StatusFunc(successOrRedirect),
+			status:  http.StatusNoContent,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Predicate accepts a 3xx status",
+			builder: New(http.MethodGet, testUrl, nil).StatusFunc(successOrRedirect),
+			status:  http.StatusFound,
+			wantErr: assert.NoError,
+		},
+		{
+			name:    "Predicate rejects a 4xx status",
+			builder: New(http.MethodGet, testUrl, nil).StatusFunc(successOrRedirect),
+			status:  http.StatusNotFound,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Predicate rejecting the default status is not overridden by it",
+			builder: New(http.MethodGet, testUrl, nil).StatusFunc(func(int) bool { return false }),
+			status:  http.StatusOK,
+			wantErr: assert.Error,
+		},
+		{
+			name:    "Explicitly listed status is accepted alongside the predicate",
+			builder: New(http.MethodGet, testUrl, nil).StatusIs(http.StatusNotFound).StatusFunc(successOrRedirect),
+			status:  http.StatusNotFound,
+			wantErr: assert.NoError,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status}
+			tt.wantErr(t, tt.builder.validateStatusCode(resp), fmt.Sprintf("validateStatusCode(%v)", tt.status))
+		})
+	}
+}
+
 func TestRequestBuilder_Do(t *testing.T) {
 	t.Run("Do GET", func(t *testing.T) {
 		ctx := context.Background()
@@ -171,3 +474,932 @@ func TestRequestBuilder_Do(t *testing.T) {
 		mock.AssertExpectations(t)
 	})
 }
+
+type failingBody struct {
+	data   []byte
+	err    error
+	closed bool
+}
+
+func (b *failingBody) Read(p []byte) (int, error) {
+	if len(b.data) == 0 {
+		return 0, b.err
+	}
+	n := copy(p, b.data)
+	b.data = b.data[n:]
+	return n, nil
+}
+
+func (b *failingBody) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRequestBuilder_DoWithRaw(t *testing.T) {
+	body := `{"id": 42, "name": "stephen", "isAdmin": false}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	var out UserResponse
+	raw, resp, err := New(http.MethodGet, server.URL, nil).DoWithRaw(context.Background(), server.Client(), &out)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, body, string(raw))
+	assert.Equal(t, resp1, out)
+}
+
+func TestRequestBuilder_Do_closesBody(t *testing.T) {
+	t.Run("Body is drained and closed on the status error path", func(t *testing.T) {
+		body := &failingBody{data: []byte(`{"message": "not found"}`), err: io.EOF}
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusNotFound, Body: body}, nil
+		})
+
+		_, err := New(http.MethodGet, testUrl, nil).Do(context.Background(), doer, nil)
+		require.Error(t, err)
+		assert.True(t, body.closed)
+		assert.Empty(t, body.data)
+	})
+	t.Run("Body is closed on the decode error path", func(t *testing.T) {
+		body := &failingBody{data: []byte(`not json`), err: io.EOF}
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+		})
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).Do(context.Background(), doer, &out)
+		require.Error(t, err)
+		assert.True(t, body.closed)
+	})
+}
+
+func TestRequestBuilder_unmarshalResponse(t *testing.T) {
+	t.Run("Body read error is surfaced and the body is closed", func(t *testing.T) {
+		readErr := errors.New("connection reset by peer")
+		body := &failingBody{data: []byte(`{"id": 42, "na`), err: readErr}
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: http.StatusOK, Body: body}, nil
+		})
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).Do(context.Background(), doer, &out)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, readErr), "expected the read error, got %v", err)
+		assert.NotContains(t, err.Error(), "unmarshal")
+		assert.True(t, body.closed)
+	})
+	t.Run("Stalled body read honors the context deadline", func(t *testing.T) {
+		release := make(chan struct{})
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderContentType, MIMEApplicationJson)
+			w.WriteHeader(http.StatusOK)
+			_, _ = w.Write([]byte(`{"id": 42, "na`))
+			w.(http.Flusher).Flush()
+			<-release
+		}))
+		defer server.Close()
+		defer close(release)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+
+		start := time.Now()
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).Do(ctx, server.Client(), &out)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected deadline exceeded, got %v", err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+	t.Run("Headerless JSON response is sniffed", func(t *testing.T) {
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			body := ioutil.NopCloser(strings.NewReader(" \n{\"id\": 42, \"name\": \"Jack\"}"))
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+		})
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).ContentType(MIMEApplicationXml).Do(context.Background(), doer, &out)
+		require.NoError(t, err)
+		assert.Equal(t, UserResponse{ID: 42, Name: "Jack"}, out)
+	})
+	t.Run("Unknown response type decodes as the request content type", func(t *testing.T) {
+		for _, contentType := range []string{"application/hal+json", "application/vnd.example.user+json", "text/plain"} {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set(HeaderContentType, contentType)
+				_ = json.NewEncoder(w).Encode(resp1)
+			}))
+
+			var out UserResponse
+			_, err := Get(server.URL).Do(context.Background(), server.Client(), &out)
+			server.Close()
+			require.NoError(t, err, contentType)
+			assert.Equal(t, resp1, out, contentType)
+		}
+	})
+	t.Run("Problem details decode as json", func(t *testing.T) {
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			body := ioutil.NopCloser(strings.NewReader(`{"title": "Not Found", "status": 404}`))
+			header := http.Header{HeaderContentType: {MIMEApplicationProblemJson}}
+			return &http.Response{StatusCode: http.StatusOK, Header: header, Body: body}, nil
+		})
+
+		var out Problem
+		_, err := New(http.MethodGet, testUrl, nil).ContentType(MIMEApplicationXml).Do(context.Background(), doer, &out)
+		require.NoError(t, err)
+		assert.Equal(t, "Not Found", out.Title)
+	})
+	t.Run("Headerless XML response is sniffed", func(t *testing.T) {
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			body := ioutil.NopCloser(strings.NewReader(`<UserResponse><ID>42</ID><Name>Jack</Name></UserResponse>`))
+			return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: body}, nil
+		})
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).Do(context.Background(), doer, &out)
+		require.NoError(t, err)
+		assert.Equal(t, UserResponse{ID: 42, Name: "Jack"}, out)
+	})
+}
+
+type ValidationErrorResponse struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func TestRequestBuilder_DoSwitch(t *testing.T) {
+	validationErr := ValidationErrorResponse{Field: "name", Message: "is required"}
+
+	t.Run("Success status decodes into the success target", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusOK, resp1, nil)
+
+		var success UserResponse
+		var failure ValidationErrorResponse
+		status, resp, err := New(http.MethodPost, testUrl, req1).DoSwitch(ctx, mock, map[int]interface{}{
+			http.StatusOK:                  &success,
+			http.StatusUnprocessableEntity: &failure,
+		})
+		require.NoError(t, err)
+		require.NotEmpty(t, resp)
+		assert.Equal(t, http.StatusOK, status)
+		assert.Equal(t, resp1, success)
+		assert.Empty(t, failure)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Validation status decodes into the validation target", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusUnprocessableEntity, validationErr, nil)
+
+		var success UserResponse
+		var failure ValidationErrorResponse
+		status, _, err := New(http.MethodPost, testUrl, req1).DoSwitch(ctx, mock, map[int]interface{}{
+			http.StatusOK:                  &success,
+			http.StatusUnprocessableEntity: &failure,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, status)
+		assert.Equal(t, validationErr, failure)
+		assert.Empty(t, success)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Unmapped status returns an error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusInternalServerError, nil, nil)
+
+		var success UserResponse
+		_, _, err := New(http.MethodPost, testUrl, req1).DoSwitch(ctx, mock, map[int]interface{}{
+			http.StatusOK: &success,
+		})
+		require.Error(t, err)
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestRequestBuilder_DoStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/users/42" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	status, err := New(http.MethodHead, server.URL+"/users/42", nil).DoStatus(context.Background(), server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+
+	status, err = New(http.MethodHead, server.URL+"/users/43", nil).DoStatus(context.Background(), server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
+func TestRequestBuilder_AcceptedPostTypes(t *testing.T) {
+	t.Run("Accept-Post media types are returned", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.OPTIONS(testUrl).
+			Return(http.StatusNoContent, nil, nil).
+			AddHeader(HeaderAcceptPost, "application/json, text/turtle")
+
+		types, err := New(http.MethodPost, testUrl, req1).AcceptedPostTypes(ctx, mock)
+		require.NoError(t, err)
+		assert.Equal(t, []string{MIMEApplicationJson, "text/turtle"}, types)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Nil Doer sends to the resolved URL", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodOptions, r.Method)
+			assert.Equal(t, "/users/1/posts", r.URL.Path)
+			assert.Equal(t, "draft=true", r.URL.RawQuery)
+			w.Header().Set(HeaderAcceptPost, MIMEApplicationJson)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		types, err := New(http.MethodPost, server.URL+"/users/{id}/posts", req1).
+			PathParam("id", "1").
+			Query("draft", "true").
+			AcceptedPostTypes(context.Background(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, []string{MIMEApplicationJson}, types)
+	})
+}
+
+type ConflictError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+func TestRequestBuilder_StatusErrorFunc(t *testing.T) {
+	decodeConflict := func(resp *http.Response) error {
+		if resp.StatusCode != http.StatusConflict {
+			return fmt.Errorf("received unexpected status code: %v", resp.StatusCode)
+		}
+		var conflictErr ConflictError
+		if err := json.NewDecoder(resp.Body).Decode(&conflictErr); err != nil {
+			return err
+		}
+		return &conflictErr
+	}
+
+	t.Run("Unexpected status returns the custom error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusConflict, ConflictError{Code: "duplicate", Message: "user exists"}, nil)
+
+		var out UserResponse
+		_, err := New(http.MethodPost, testUrl, req1).
+			StatusErrorFunc(decodeConflict).
+			Do(ctx, mock, &out)
+		var conflictErr *ConflictError
+		require.True(t, errors.As(err, &conflictErr), "expected a ConflictError, got %v", err)
+		assert.Equal(t, "duplicate", conflictErr.Code)
+		assert.Equal(t, "user exists", conflictErr.Message)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Nil error from the func proceeds to decode", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusAccepted, resp1, nil)
+
+		var out UserResponse
+		_, err := New(http.MethodPost, testUrl, req1).
+			StatusErrorFunc(func(resp *http.Response) error { return nil }).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestRequestBuilder_ErrorStatusIn(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		if r.URL.Path == "/users/duplicate" {
+			w.WriteHeader(http.StatusConflict)
+			_ = json.NewEncoder(w).Encode(ConflictError{Code: "duplicate", Message: "user exists"})
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	defer server.Close()
+
+	t.Run("Success status decodes into out", func(t *testing.T) {
+		var out UserResponse
+		var errOut ConflictError
+		_, err := New(http.MethodPost, server.URL+"/users", req1).
+			StatusIs(http.StatusCreated).
+			ErrorStatusIn([]int{http.StatusConflict}, &errOut).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Empty(t, errOut)
+	})
+	t.Run("Error status decodes into the error target", func(t *testing.T) {
+		var out UserResponse
+		var errOut ConflictError
+		_, err := New(http.MethodPost, server.URL+"/users/duplicate", req1).
+			StatusIs(http.StatusCreated).
+			ErrorStatusIn([]int{http.StatusConflict}, &errOut).
+			Do(context.Background(), server.Client(), &out)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, ErrConflict))
+		assert.Equal(t, ConflictError{Code: "duplicate", Message: "user exists"}, errOut)
+		assert.Empty(t, out)
+	})
+}
+
+func TestRequestBuilder_DecodeInto(t *testing.T) {
+	type ValidationError struct {
+		Field string `json:"field"`
+	}
+	type ServerError struct {
+		TraceID string `json:"traceId"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		switch r.URL.Path {
+		case "/invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(ValidationError{Field: "name"})
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			_ = json.NewEncoder(w).Encode(ServerError{TraceID: "abc123"})
+		default:
+			_ = json.NewEncoder(w).Encode(resp1)
+		}
+	}))
+	defer server.Close()
+
+	var ok UserResponse
+	var invalid ValidationError
+	var broken ServerError
+	newBuilder := func(path string) *RequestBuilder {
+		ok, invalid, broken = UserResponse{}, ValidationError{}, ServerError{}
+		return New(http.MethodGet, server.URL+path, nil).
+			DecodeInto(http.StatusOK, &ok).
+			DecodeInto(http.StatusBadRequest, &invalid).
+			DecodeInto(http.StatusInternalServerError, &broken)
+	}
+
+	t.Run("200", func(t *testing.T) {
+		var out UserResponse
+		_, err := newBuilder("/").Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, ok)
+		assert.Empty(t, out)
+		assert.Empty(t, invalid)
+		assert.Empty(t, broken)
+	})
+	t.Run("400", func(t *testing.T) {
+		_, err := newBuilder("/invalid").Do(context.Background(), server.Client(), nil)
+		assert.True(t, errors.Is(err, ErrBadRequest))
+		assert.Equal(t, ValidationError{Field: "name"}, invalid)
+		assert.Empty(t, ok)
+		assert.Empty(t, broken)
+	})
+	t.Run("500", func(t *testing.T) {
+		_, err := newBuilder("/broken").Do(context.Background(), server.Client(), nil)
+		assert.True(t, errors.Is(err, ErrInternalServer))
+		assert.Equal(t, ServerError{TraceID: "abc123"}, broken)
+		assert.Empty(t, ok)
+		assert.Empty(t, invalid)
+	})
+	t.Run("Unregistered status falls back to out", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			DecodeInto(http.StatusBadRequest, &invalid).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	})
+}
+
+func TestRequestBuilder_DecodeErrors(t *testing.T) {
+	type APIError struct {
+		Message string `json:"message"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		switch r.URL.Path {
+		case "/users/1":
+			_ = json.NewEncoder(w).Encode(resp1)
+		case "/users":
+			w.WriteHeader(http.StatusCreated)
+			_ = json.NewEncoder(w).Encode(resp1)
+		case "/proxy":
+			w.Header().Set(HeaderContentType, "text/html")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte("<html><body>Not Found</body></html>"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_ = json.NewEncoder(w).Encode(APIError{Message: "user not found"})
+		}
+	}))
+	defer server.Close()
+
+	t.Run("200 decodes into out", func(t *testing.T) {
+		var out UserResponse
+		var apiErr APIError
+		_, err := Get(server.URL+"/users/1").
+			DecodeErrors(&apiErr).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Empty(t, apiErr)
+	})
+	t.Run("Other 2xx statuses are successes", func(t *testing.T) {
+		var out UserResponse
+		var apiErr APIError
+		_, err := Post(server.URL+"/users", req1).
+			DecodeErrors(&apiErr).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	})
+	t.Run("404 decodes into the error target", func(t *testing.T) {
+		var out UserResponse
+		var apiErr APIError
+		_, err := Get(server.URL+"/users/2").
+			DecodeErrors(&apiErr).
+			Do(context.Background(), server.Client(), &out)
+		assert.True(t, errors.Is(err, ErrNotFound))
+		assert.Equal(t, APIError{Message: "user not found"}, apiErr)
+		assert.Empty(t, out)
+	})
+	t.Run("Error body that can't be decoded keeps the status", func(t *testing.T) {
+		var apiErr APIError
+		_, err := Get(server.URL+"/proxy").
+			DecodeErrors(&apiErr).
+			Do(context.Background(), server.Client(), nil)
+		assert.True(t, errors.Is(err, ErrNotFound), "expected ErrNotFound, got %v", err)
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr), "expected a StatusError, got %v", err)
+		assert.Error(t, statusErr.DecodeErr)
+		assert.Empty(t, apiErr)
+	})
+	t.Run("Explicit expected status still applies", func(t *testing.T) {
+		var apiErr APIError
+		_, err := Post(server.URL+"/users", req1).
+			StatusIs(http.StatusOK).
+			DecodeErrors(&apiErr).
+			Do(context.Background(), server.Client(), nil)
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr), "expected a StatusError, got %v", err)
+		assert.Equal(t, http.StatusCreated, statusErr.StatusCode)
+	})
+}
+
+func TestRequestBuilder_EnforceAcceptedResponseType(t *testing.T) {
+	newServer := func(contentType string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set(HeaderContentType, contentType)
+			if contentType == MIMEApplicationXml {
+				_ = xml.NewEncoder(w).Encode(resp1)
+				return
+			}
+			_ = json.NewEncoder(w).Encode(resp1)
+		}))
+	}
+
+	t.Run("Response type not in Accept returns an error", func(t *testing.T) {
+		server := newServer(MIMEApplicationXml)
+		defer server.Close()
+
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			Accept(MIMEApplicationJson).
+			EnforceAcceptedResponseType().
+			Do(context.Background(), server.Client(), &out)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "not accepted")
+	})
+	t.Run("Response type matching Accept decodes", func(t *testing.T) {
+		server := newServer("application/json; charset=utf-8")
+		defer server.Close()
+
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			Accept(MIMEApplicationXml, "application/*").
+			EnforceAcceptedResponseType().
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+	})
+}
+
+func TestRequestBuilder_ExpectEmptyBody(t *testing.T) {
+	t.Run("Non-empty body returns an error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil)
+
+		_, err := New(http.MethodGet, testUrl, nil).
+			ExpectEmptyBody().
+			Do(ctx, mock, nil)
+		require.Error(t, err)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Empty body passes", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+		defer server.Close()
+
+		resp, err := New(http.MethodGet, server.URL, nil).
+			ExpectEmptyBody().
+			Do(context.Background(), server.Client(), nil)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+}
+
+func TestRequestBuilder_headerHelpers(t *testing.T) {
+	tests := []struct {
+		name   string
+		apply  func(b *RequestBuilder) *RequestBuilder
+		header string
+		want   string
+	}{
+		{"Accept", func(b *RequestBuilder) *RequestBuilder { return b.Accept(MIMEApplicationJson) }, HeaderAccept, MIMEApplicationJson},
+		{"UserAgent", func(b *RequestBuilder) *RequestBuilder { return b.UserAgent("client/1.0") }, HeaderUserAgent, "client/1.0"},
+		{"IfMatch", func(b *RequestBuilder) *RequestBuilder { return b.IfMatch(`"v1"`) }, HeaderIfMatch, `"v1"`},
+		{"IdempotencyKey", func(b *RequestBuilder) *RequestBuilder { return b.IdempotencyKey("key") }, HeaderIdempotencyKey, "key"},
+		{"BearerToken", func(b *RequestBuilder) *RequestBuilder { return b.BearerToken("abc") }, HeaderAuthorization, "Bearer abc"},
+		{"Referer", func(b *RequestBuilder) *RequestBuilder { return b.Referer("https://example.com/") }, HeaderReferer, "https://example.com/"},
+		{"Origin", func(b *RequestBuilder) *RequestBuilder { return b.Origin("https://example.com") }, HeaderOrigin, "https://example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := tt.apply(New(http.MethodGet, testUrl, nil)).Build(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, req.Header.Get(tt.header))
+		})
+	}
+}
+
+func TestRequestBuilder_SetRawHeader(t *testing.T) {
+	req, err := New(http.MethodGet, testUrl, nil).
+		SetRawHeader("X-foo", "bar").
+		Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bar"}, req.Header["X-foo"])
+	assert.NotContains(t, req.Header, "X-Foo")
+
+	var wire bytes.Buffer
+	require.NoError(t, req.Header.Write(&wire))
+	assert.Contains(t, wire.String(), "X-foo: bar\r\n")
+}
+
+func TestRequestBuilder_AddTrailer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"chunked"}, r.TransferEncoding)
+		var in UserRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		_, err := io.Copy(ioutil.Discard, r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, req1, in)
+
+		// Trailers are only available once the body has been read to the end
+		assert.Equal(t, "0", r.Trailer.Get("Grpc-Status"))
+		assert.Equal(t, "ok", r.Trailer.Get("Grpc-Message"))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	_, err := New(http.MethodPost, server.URL, req1).
+		AddTrailer("Grpc-Status", "0").
+		AddTrailer("Grpc-Message", "ok").
+		StatusIs(http.StatusNoContent).
+		ExpectEmptyBody().
+		Do(context.Background(), server.Client(), nil)
+	require.NoError(t, err)
+}
+
+func TestRequestBuilder_Chunked(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"chunked"}, r.TransferEncoding)
+		assert.Equal(t, int64(-1), r.ContentLength)
+		var in UserRequest
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		assert.Equal(t, req1, in)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	builder := New(http.MethodPut, server.URL, req1).Chunked()
+	req, err := builder.Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"chunked"}, req.TransferEncoding)
+	assert.Equal(t, int64(-1), req.ContentLength)
+
+	_, err = builder.StatusIs(http.StatusNoContent).
+		ExpectEmptyBody().
+		Do(context.Background(), server.Client(), nil)
+	require.NoError(t, err)
+
+	t.Run("Request without a body is unaffected", func(t *testing.T) {
+		req, err := Get(server.URL).Chunked().Build(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, req.TransferEncoding)
+	})
+}
+
+func TestRequestBuilder_BearerToken(t *testing.T) {
+	ctx := context.Background()
+	mock := httpmock.NewMock()
+	mock.GET(testUrl).WithBearerToken("abc").Return(http.StatusOK, resp1, nil)
+
+	var out UserResponse
+	_, err := New(http.MethodGet, testUrl, nil).
+		BearerToken("abc").
+		Do(ctx, mock, &out)
+	require.NoError(t, err)
+	assert.Equal(t, resp1, out)
+	mock.AssertExpectations(t)
+}
+
+func TestRequestBuilder_BasicAuth(t *testing.T) {
+	req, err := New(http.MethodGet, testUrl, nil).
+		BasicAuth("jack", "secret").
+		Build(context.Background())
+	require.NoError(t, err)
+	username, password, ok := req.BasicAuth()
+	require.True(t, ok)
+	assert.Equal(t, "jack", username)
+	assert.Equal(t, "secret", password)
+}
+
+func TestRequestBuilder_RefererAndOrigin(t *testing.T) {
+	t.Run("Headers are set", func(t *testing.T) {
+		req, err := New(http.MethodGet, testUrl, nil).
+			Referer("https://example.com/dashboard?tab=users").
+			Origin("https://example.com").
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/dashboard?tab=users", req.Header.Get(HeaderReferer))
+		assert.Equal(t, "https://example.com", req.Header.Get(HeaderOrigin))
+	})
+	t.Run("Invalid referer returns an error from Build", func(t *testing.T) {
+		_, err := New(http.MethodGet, testUrl, nil).
+			Referer("https://example.com/%zz").
+			Build(context.Background())
+		require.Error(t, err)
+	})
+	t.Run("Relative origin returns an error from Build", func(t *testing.T) {
+		_, err := New(http.MethodGet, testUrl, nil).
+			Origin("/dashboard").
+			Build(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestSetDefaultContentType(t *testing.T) {
+	defer func() {
+		require.NoError(t, SetDefaultContentType(MIMEApplicationJson))
+	}()
+
+	t.Run("Unsupported content type returns an error", func(t *testing.T) {
+		require.Error(t, SetDefaultContentType("application/morse-code"))
+	})
+	t.Run("Default content type is used by New", func(t *testing.T) {
+		expectedBytes, err := xml.Marshal(req1)
+		require.NoError(t, err)
+
+		require.NoError(t, SetDefaultContentType(MIMEApplicationXml))
+		req, err := New(http.MethodPost, testUrl, req1).Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MIMEApplicationXml, req.Header.Get(HeaderContentType))
+
+		bodyBytes, err := ioutil.ReadAll(req.Body)
+		require.NoError(t, err)
+		assert.Equal(t, expectedBytes, bodyBytes)
+	})
+	t.Run("ContentType overrides the default", func(t *testing.T) {
+		require.NoError(t, SetDefaultContentType(MIMEApplicationXml))
+		req, err := New(http.MethodPost, testUrl, req1).
+			ContentType(MIMEApplicationJson).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, MIMEApplicationJson, req.Header.Get(HeaderContentType))
+	})
+}
+
+func TestNewURL(t *testing.T) {
+	u := &url.URL{Scheme: "https", Host: "example.com", Path: "/api/v1/users"}
+	query := u.Query()
+	query.Set("name", "jack ramey")
+	query.Set("active", "true")
+	u.RawQuery = query.Encode()
+
+	builder := NewURL(http.MethodGet, u, nil)
+	u.Host = "changed.example.com"
+
+	req, err := builder.Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.com/api/v1/users?active=true&name=jack+ramey", req.URL.String())
+	assert.Equal(t, "example.com", req.Host)
+	assert.Equal(t, "jack ramey", req.URL.Query().Get("name"))
+
+	t.Run("Builder query parameters are merged", func(t *testing.T) {
+		req, err := NewURL(http.MethodGet, &url.URL{Scheme: "https", Host: "example.com", RawQuery: "a=1"}, nil).
+			Query("b", "2").
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com?a=1&b=2", req.URL.String())
+	})
+}
+
+func TestRequestBuilder_Do_expectedRedirect(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderLocation, "/users/42")
+		w.Header().Set(HeaderContentType, "text/html")
+		w.WriteHeader(http.StatusFound)
+		_, _ = w.Write([]byte(`<a href="/users/42">Found</a>.`))
+	}))
+	defer server.Close()
+
+	doer := NewClientBuilder().NoRedirects().Build()
+
+	var out UserResponse
+	resp, err := New(http.MethodPost, server.URL, req1).
+		StatusIs(http.StatusFound).
+		Do(context.Background(), doer, &out)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.Equal(t, "/users/42", resp.Header.Get(HeaderLocation))
+	assert.Equal(t, UserResponse{}, out)
+
+	t.Run("Unexpected redirect is still an error", func(t *testing.T) {
+		_, err := New(http.MethodPost, server.URL, req1).Do(context.Background(), doer, &out)
+		var statusErr *StatusError
+		require.True(t, errors.As(err, &statusErr), "expected a StatusError, got %v", err)
+		assert.Equal(t, http.StatusFound, statusErr.StatusCode)
+	})
+}
+
+func TestDefaultBaseURL(t *testing.T) {
+	defer func() {
+		require.NoError(t, DefaultBaseURL(""))
+	}()
+
+	t.Run("Relative base url returns an error", func(t *testing.T) {
+		require.Error(t, DefaultBaseURL("/v1"))
+	})
+	t.Run("Path-only url resolves against the base", func(t *testing.T) {
+		require.NoError(t, DefaultBaseURL("https://api.example.com/"))
+		req, err := New(http.MethodGet, "/v1/users/{id}", nil).
+			PathParam("id", "42").
+			Query("active", "true").
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "https://api.example.com/v1/users/42?active=true", req.URL.String())
+	})
+	t.Run("Absolute url ignores the base", func(t *testing.T) {
+		require.NoError(t, DefaultBaseURL("https://api.example.com"))
+		req, err := New(http.MethodGet, testUrl, nil).Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, testUrl, req.URL.String())
+	})
+	t.Run("Validate accepts a path-only url with a base", func(t *testing.T) {
+		require.NoError(t, DefaultBaseURL("https://api.example.com"))
+		assert.NoError(t, Get("/v1/users").Validate())
+	})
+}
+
+type UserPatch struct {
+	Name    *string `json:"name,omitempty"`
+	IsAdmin *bool   `json:"isAdmin"`
+}
+
+func TestRequestBuilder_Do_nilBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, []string{"0"}, r.Header.Values("Content-Length"))
+		assert.Empty(t, r.Header.Values(HeaderContentType))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	for _, builder := range []*RequestBuilder{Put(server.URL, nil), Patch(server.URL, nil)} {
+		t.Run(builder.httpMethod, func(t *testing.T) {
+			req, err := builder.Build(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, http.NoBody, req.Body)
+			assert.Equal(t, int64(0), req.ContentLength)
+			assert.Empty(t, req.Header.Get(HeaderContentType))
+
+			_, err = builder.StatusIs(http.StatusNoContent).ExpectEmptyBody().Do(context.Background(), server.Client(), nil)
+			require.NoError(t, err)
+		})
+	}
+	t.Run("Clearing the body drops its content type", func(t *testing.T) {
+		builder := Put(server.URL, req1)
+		_, err := builder.Build(context.Background())
+		require.NoError(t, err)
+
+		req, err := builder.Body(nil).Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), req.ContentLength)
+		assert.Empty(t, req.Header.Get(HeaderContentType))
+	})
+}
+
+func TestPatch(t *testing.T) {
+	name := "jackson"
+	recorder := httpmock.NewRecorder(http.StatusOK, resp1)
+
+	var out UserResponse
+	_, err := Patch(testUrl, UserPatch{Name: &name}).
+		ContentType(MIMEMergePatchJson).
+		Do(context.Background(), recorder, &out)
+	require.NoError(t, err)
+	assert.Equal(t, resp1, out)
+
+	requests := recorder.Requests()
+	require.Len(t, requests, 1)
+	assert.Equal(t, http.MethodPatch, requests[0].Request.Method)
+	assert.Equal(t, MIMEMergePatchJson, requests[0].Request.Header.Get(HeaderContentType))
+	assert.JSONEq(t, `{"name":"jackson","isAdmin":null}`, string(requests[0].Body))
+}
+
+func TestRequestBuilder_DecodeWith(t *testing.T) {
+	strictDecode := func(data []byte, out interface{}) error {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(out)
+	}
+
+	t.Run("Decoder is used for the matching content type", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil)
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			DecodeWith(MIMEApplicationJson, strictDecode).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Decoder errors are returned", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, map[string]interface{}{"id": 1, "unknown": true}, nil)
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			DecodeWith(MIMEApplicationJson, strictDecode).
+			Do(ctx, mock, &out)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown field")
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestRequestBuilder_DecodePath(t *testing.T) {
+	wrapped := map[string]interface{}{
+		"data": map[string]interface{}{"user": resp1},
+		"meta": map[string]interface{}{"page": 1},
+	}
+
+	t.Run("Subtree at the path is decoded", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, wrapped, nil)
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			DecodePath("data.user").
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Missing path returns an error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, wrapped, nil)
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			DecodePath("data.account").
+			Do(ctx, mock, &out)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `key "account" not found`)
+		mock.AssertExpectations(t)
+	})
+}
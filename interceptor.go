@@ -0,0 +1,53 @@
+package httprequest
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	responseInterceptorsMu sync.RWMutex
+	responseInterceptors   []*responseInterceptor
+)
+
+type responseInterceptor struct {
+	fn func(*http.Response) error
+}
+
+// RegisterResponseInterceptor registers fn to be called with every response received by any builder, before its
+// status code is validated, for cross-cutting concerns like noticing expired credentials. An error from fn fails the
+// request with that error, so a sentinel of the caller's choosing can signal it should be retried after a refresh.
+// fn must not consume the response body. The returned function unregisters fn.
+func RegisterResponseInterceptor(fn func(*http.Response) error) (unregister func()) {
+	interceptor := &responseInterceptor{fn: fn}
+
+	responseInterceptorsMu.Lock()
+	responseInterceptors = append(responseInterceptors, interceptor)
+	responseInterceptorsMu.Unlock()
+
+	return func() {
+		responseInterceptorsMu.Lock()
+		defer responseInterceptorsMu.Unlock()
+
+		for i, registered := range responseInterceptors {
+			if registered == interceptor {
+				responseInterceptors = append(responseInterceptors[:i:i], responseInterceptors[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+func interceptResponse(resp *http.Response) error {
+	responseInterceptorsMu.RLock()
+	interceptors := responseInterceptors
+	responseInterceptorsMu.RUnlock()
+
+	for _, interceptor := range interceptors {
+		err := interceptor.fn(resp)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterResponseInterceptor(t *testing.T) {
+	t.Run("Interceptor sees every response", func(t *testing.T) {
+		var unauthorized int32
+		unregister := RegisterResponseInterceptor(func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusUnauthorized {
+				atomic.AddInt32(&unauthorized, 1)
+			}
+			return nil
+		})
+		defer unregister()
+
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).WithBearerToken("expired").Return(http.StatusUnauthorized, nil, nil)
+		mock.GET(testUrl).WithBearerToken("valid").Return(http.StatusOK, resp1, nil)
+
+		for _, token := range []string{"expired", "valid", "expired"} {
+			_, _ = New(http.MethodGet, testUrl, nil).BearerToken(token).Do(context.Background(), mock, nil)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&unauthorized))
+
+		unregister()
+		_, _ = New(http.MethodGet, testUrl, nil).BearerToken("expired").Do(context.Background(), mock, nil)
+		assert.Equal(t, int32(2), atomic.LoadInt32(&unauthorized))
+	})
+	t.Run("Interceptor error fails the request", func(t *testing.T) {
+		errRefresh := errors.New("credentials refreshed, retry")
+		unregister := RegisterResponseInterceptor(func(resp *http.Response) error {
+			if resp.StatusCode == http.StatusUnauthorized {
+				return errRefresh
+			}
+			return nil
+		})
+		defer unregister()
+
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusUnauthorized, nil, nil)
+
+		_, err := New(http.MethodGet, testUrl, nil).Do(context.Background(), mock, nil)
+		require.Error(t, err)
+		assert.True(t, errors.Is(err, errRefresh))
+	})
+}
@@ -0,0 +1,59 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+)
+
+// WithRequestLimit makes Build fail with a SizeLimitError when the encoded request body is larger than n bytes.
+func (b *RequestBuilder) WithRequestLimit(n int64) *RequestBuilder {
+	b.requestLimit = n
+	return b
+}
+
+// WithResponseLimit makes Do fail with a SizeLimitError instead of decoding a response body larger than n bytes. At
+// most n+1 bytes of the body are read.
+func (b *RequestBuilder) WithResponseLimit(n int64) *RequestBuilder {
+	b.responseLimit = n
+	return b
+}
+
+func (b *RequestBuilder) checkRequestLimit(size int64) error {
+	if b.requestLimit > 0 && size > b.requestLimit {
+		return &SizeLimitError{Side: "request", Limit: b.requestLimit, Observed: size}
+	}
+	return nil
+}
+
+// readResponseBody reads the response body into buf, enforcing the response limit if one is configured.
+func (b *RequestBuilder) readResponseBody(ctx context.Context, resp *http.Response, buf *bytes.Buffer) error {
+	if resp.ContentLength > 0 && resp.ContentLength <= maxPooledBufferSize {
+		// Room for the final read that finds EOF avoids growing the buffer once it holds the whole body
+		buf.Grow(int(resp.ContentLength) + bytes.MinRead)
+	}
+
+	if b.responseLimit <= 0 {
+		return readBody(ctx, resp.Body, buf)
+	}
+
+	if resp.ContentLength > b.responseLimit {
+		drainAndClose(resp.Body)
+		return &SizeLimitError{Side: "response", Limit: b.responseLimit, Observed: resp.ContentLength}
+	}
+
+	limited := struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, b.responseLimit+1), resp.Body}
+	err := readBody(ctx, limited, buf)
+	if err != nil {
+		return err
+	}
+
+	if int64(buf.Len()) > b.responseLimit {
+		return &SizeLimitError{Side: "response", Limit: b.responseLimit, Observed: int64(buf.Len())}
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package httprequest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_WithRequestLimit(t *testing.T) {
+	bodyBytes, err := json.Marshal(req1)
+	require.NoError(t, err)
+
+	t.Run("Body over the limit returns a SizeLimitError", func(t *testing.T) {
+		_, err := New(http.MethodPost, testUrl, req1).
+			WithRequestLimit(10).
+			Build(context.Background())
+		var sizeErr *SizeLimitError
+		require.True(t, errors.As(err, &sizeErr), "expected a SizeLimitError, got %v", err)
+		assert.Equal(t, "request", sizeErr.Side)
+		assert.Equal(t, int64(10), sizeErr.Limit)
+		assert.Equal(t, int64(len(bodyBytes)), sizeErr.Observed)
+	})
+	t.Run("Body within the limit is sent", func(t *testing.T) {
+		_, err := New(http.MethodPost, testUrl, req1).
+			WithRequestLimit(int64(len(bodyBytes))).
+			Build(context.Background())
+		require.NoError(t, err)
+	})
+}
+
+func TestRequestBuilder_WithResponseLimit(t *testing.T) {
+	body := `{"id": 42, "name": "` + strings.Repeat("a", 100) + `"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		if r.URL.Path == "/streamed" {
+			// Flushing before writing the body leaves the response without a Content-Length
+			w.(http.Flusher).Flush()
+		}
+		_, _ = w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	t.Run("Response with a Content-Length over the limit returns a SizeLimitError", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			WithResponseLimit(50).
+			Do(context.Background(), server.Client(), &out)
+		var sizeErr *SizeLimitError
+		require.True(t, errors.As(err, &sizeErr), "expected a SizeLimitError, got %v", err)
+		assert.Equal(t, "response", sizeErr.Side)
+		assert.Equal(t, int64(50), sizeErr.Limit)
+		assert.Equal(t, int64(len(body)), sizeErr.Observed)
+	})
+	t.Run("Streamed response over the limit returns a SizeLimitError", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL+"/streamed", nil).
+			WithResponseLimit(50).
+			Do(context.Background(), server.Client(), &out)
+		var sizeErr *SizeLimitError
+		require.True(t, errors.As(err, &sizeErr), "expected a SizeLimitError, got %v", err)
+		assert.Equal(t, "response", sizeErr.Side)
+		assert.Equal(t, int64(50), sizeErr.Limit)
+		assert.Equal(t, int64(51), sizeErr.Observed)
+	})
+	t.Run("Response within the limit is decoded", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			WithResponseLimit(int64(len(body))).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, 42, out.ID)
+	})
+}
+
+func TestRequestBuilder_WithResponseLimit_gzip(t *testing.T) {
+	// Ten megabytes of zeros compress to a few kilobytes, well under the limit
+	var compressed bytes.Buffer
+	gw := gzip.NewWriter(&compressed)
+	_, err := gw.Write([]byte(`{"id": 42, "name": "` + strings.Repeat("0", 10<<20) + `"}`))
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+	require.Less(t, compressed.Len(), 64<<10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		w.Header().Set(HeaderContentEncoding, EncodingGzip)
+		_, _ = w.Write(compressed.Bytes())
+	}))
+	defer server.Close()
+
+	t.Run("Decompressed body over the limit returns a SizeLimitError", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			SetHeader(HeaderAcceptEncoding, EncodingGzip).
+			WithResponseLimit(64<<10).
+			Do(context.Background(), server.Client(), &out)
+		var sizeErr *SizeLimitError
+		require.True(t, errors.As(err, &sizeErr), "expected a SizeLimitError, got %v", err)
+		assert.Equal(t, "response", sizeErr.Side)
+		assert.Equal(t, int64(64<<10), sizeErr.Limit)
+		assert.Equal(t, int64(64<<10+1), sizeErr.Observed)
+	})
+	t.Run("Decompressed body within the limit is decoded", func(t *testing.T) {
+		var out UserResponse
+		_, err := New(http.MethodGet, server.URL, nil).
+			SetHeader(HeaderAcceptEncoding, EncodingGzip).
+			WithResponseLimit(11<<20).
+			Do(context.Background(), server.Client(), &out)
+		require.NoError(t, err)
+		assert.Equal(t, 42, out.ID)
+	})
+}
@@ -0,0 +1,36 @@
+package httprequest
+
+import (
+	"errors"
+	"net/http"
+	"time"
+)
+
+// Logger receives a line for every request completed by Do.
+type Logger interface {
+	Logf(format string, args ...interface{})
+}
+
+// WithLogger logs the method, URL, status, and duration of the request to l once Do completes, along with the error
+// if it failed.
+func (b *RequestBuilder) WithLogger(l Logger) *RequestBuilder {
+	b.logger = l
+	return b
+}
+
+func (b *RequestBuilder) logCompletion(resp *http.Response, err error, elapsed time.Duration) {
+	status := 0
+	if resp != nil {
+		status = resp.StatusCode
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		status = statusErr.StatusCode
+	}
+
+	if err != nil {
+		b.logger.Logf("%s %s %d %s error: %v", b.httpMethod, b.resolveURL(), status, elapsed, err)
+		return
+	}
+	b.logger.Logf("%s %s %d %s", b.httpMethod, b.resolveURL(), status, elapsed)
+}
@@ -0,0 +1,53 @@
+package httprequest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type capturingLogger struct {
+	lines []string
+}
+
+func (l *capturingLogger) Logf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestRequestBuilder_WithLogger(t *testing.T) {
+	t.Run("Successful request is logged", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil)
+		logger := &capturingLogger{}
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			WithLogger(logger).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		require.Len(t, logger.lines, 1)
+		assert.Contains(t, logger.lines[0], "GET "+testUrl+" 200")
+		mock.AssertExpectations(t)
+	})
+	t.Run("Failed request is logged with the error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusNotFound, nil, nil)
+		logger := &capturingLogger{}
+
+		_, err := New(http.MethodGet, testUrl, nil).
+			WithLogger(logger).
+			Do(ctx, mock, nil)
+		require.Error(t, err)
+		require.Len(t, logger.lines, 1)
+		assert.Contains(t, logger.lines[0], "GET "+testUrl+" 404")
+		assert.Contains(t, logger.lines[0], "error: received unexpected status code: 404")
+		mock.AssertExpectations(t)
+	})
+}
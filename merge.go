@@ -0,0 +1,75 @@
+package httprequest
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Merge returns a new builder combining the receiver with the settings explicitly configured on other. Headers are
+// unioned, with other's values winning for keys present on both, and other's content type and expected statuses
+// replace the receiver's when set. Neither builder is modified.
+func (b *RequestBuilder) Merge(other *RequestBuilder) *RequestBuilder {
+	merged := b.clone()
+
+	for key, vals := range other.header {
+		if merged.header == nil {
+			merged.header = http.Header{}
+		}
+		merged.header[key] = append([]string(nil), vals...)
+	}
+
+	for name, value := range other.pathParams {
+		merged.PathParam(name, value)
+	}
+
+	if other.contentTypeSet {
+		merged.contentType = other.contentType
+		merged.contentTypeSet = true
+		merged.encoder = other.encoder
+		merged.invalidateBody()
+	}
+
+	if other.expectedStatusSet {
+		merged.StatusIn(append([]int(nil), other.expectedStatusCodes...))
+	}
+
+	return merged
+}
+
+// clone returns a copy of the builder that shares no maps or slices with it, so configuring one never changes the
+// other.
+func (b *RequestBuilder) clone() *RequestBuilder {
+	c := *b
+	c.header = b.header.Clone()
+	c.trailer = b.trailer.Clone()
+	c.expectedStatusCodes = append([]int(nil), b.expectedStatusCodes...)
+	c.requiredFields = append([]string(nil), b.requiredFields...)
+	c.formParts = append([]formPart(nil), b.formParts...)
+	c.onRequest = append([]func(*http.Request) error(nil), b.onRequest...)
+
+	if b.pathParams != nil {
+		c.pathParams = make(map[string]string, len(b.pathParams))
+		for name, value := range b.pathParams {
+			c.pathParams[name] = value
+		}
+	}
+	if b.query != nil {
+		c.query = make(url.Values, len(b.query))
+		for key, vals := range b.query {
+			c.query[key] = append([]string(nil), vals...)
+		}
+	}
+	if b.decodeTargets != nil {
+		c.decodeTargets = make(map[int]interface{}, len(b.decodeTargets))
+		for status, target := range b.decodeTargets {
+			c.decodeTargets[status] = target
+		}
+	}
+	if b.decoders != nil {
+		c.decoders = make(map[string]func([]byte, interface{}) error, len(b.decoders))
+		for contentType, decoder := range b.decoders {
+			c.decoders[contentType] = decoder
+		}
+	}
+	return &c
+}
@@ -0,0 +1,98 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_Merge(t *testing.T) {
+	base := New(http.MethodPost, testUrl, req1).
+		BearerToken("base-token").
+		SetHeader("X-Client", "httprequest").
+		SetHeader("X-Trace", "base")
+	override := New(http.MethodPost, testUrl, nil).
+		SetHeader("X-Trace", "override").
+		AddHeader("X-Request-Id", "abc").
+		ContentType(MIMEApplicationXml).
+		StatusIs(http.StatusCreated)
+
+	merged := base.Merge(override)
+
+	req, err := merged.Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer base-token", req.Header.Get(HeaderAuthorization))
+	assert.Equal(t, "httprequest", req.Header.Get("X-Client"))
+	assert.Equal(t, []string{"override"}, req.Header.Values("X-Trace"))
+	assert.Equal(t, "abc", req.Header.Get("X-Request-Id"))
+	assert.Equal(t, MIMEApplicationXml, req.Header.Get(HeaderContentType))
+	assert.Equal(t, []int{http.StatusCreated}, merged.expectedStatusCodes)
+
+	// The base builder is left untouched
+	assert.Equal(t, "base", base.header.Get("X-Trace"))
+	assert.Empty(t, base.header.Get("X-Request-Id"))
+	assert.Equal(t, MIMEApplicationJson, base.contentType)
+	assert.Equal(t, []int{http.StatusOK}, base.expectedStatusCodes)
+}
+
+func TestRequestBuilder_Merge_unsetSettingsKeepBase(t *testing.T) {
+	base := New(http.MethodGet, testUrl, nil).
+		ContentType(MIMEApplicationXml).
+		StatusIs(http.StatusAccepted)
+
+	merged := base.Merge(New(http.MethodGet, testUrl, nil).SetHeader("X-Trace", "override"))
+	assert.Equal(t, MIMEApplicationXml, merged.contentType)
+	assert.Equal(t, []int{http.StatusAccepted}, merged.expectedStatusCodes)
+	assert.Equal(t, "override", merged.header.Get("X-Trace"))
+}
+
+func TestRequestBuilder_Merge_isolatesBuilders(t *testing.T) {
+	var calls []string
+	hook := func(name string) func(*http.Request) error {
+		return func(*http.Request) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+
+	// Three hooks leave spare capacity in the slice, which appends on a shared copy would write into
+	base := New(http.MethodGet, testUrl+"/{id}", nil).
+		PathParam("id", "1").
+		Query("version", "2").
+		DecodeInto(http.StatusNotFound, &UserResponse{}).
+		OnRequest(hook("base-1")).
+		OnRequest(hook("base-2")).
+		OnRequest(hook("base-3"))
+	baseURL, err := base.ResolveURL()
+	require.NoError(t, err)
+
+	first := base.Merge(New(http.MethodGet, testUrl, nil)).
+		PathParam("id", "2").
+		Query("page", "1").
+		DecodeInto(http.StatusConflict, &UserResponse{}).
+		OnRequest(hook("first"))
+	second := base.Merge(New(http.MethodGet, testUrl, nil)).
+		OnRequest(hook("second"))
+
+	resolved, err := base.ResolveURL()
+	require.NoError(t, err)
+	assert.Equal(t, baseURL, resolved)
+	assert.Len(t, base.decodeTargets, 1)
+	assert.Len(t, base.onRequest, 3)
+
+	resolved, err = first.ResolveURL()
+	require.NoError(t, err)
+	assert.Equal(t, testUrl+"/2?page=1&version=2", resolved)
+
+	_, err = first.Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base-1", "base-2", "base-3", "first"}, calls)
+
+	calls = nil
+	_, err = second.Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"base-1", "base-2", "base-3", "second"}, calls)
+}
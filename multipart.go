@@ -0,0 +1,149 @@
+package httprequest
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+)
+
+const MIMEApplicationOctetStream = "application/octet-stream"
+
+type formPart struct {
+	field    string
+	filename string
+	value    string
+	reader   io.Reader
+	gzip     bool
+}
+
+// AddFormField adds a plain field to a multipart/form-data body. Form parts take the place of the builder's body.
+func (b *RequestBuilder) AddFormField(field, value string) *RequestBuilder {
+	b.formParts = append(b.formParts, formPart{field: field, value: value})
+	b.invalidateBody()
+	return b
+}
+
+// AddFormFile adds a file part to a multipart/form-data body. The reader is consumed the first time the request is
+// built.
+func (b *RequestBuilder) AddFormFile(field, filename string, r io.Reader) *RequestBuilder {
+	b.formParts = append(b.formParts, formPart{field: field, filename: filename, reader: r})
+	b.invalidateBody()
+	return b
+}
+
+// AddGzippedFormFile adds a file part like AddFormFile, but gzips its contents and sets Content-Encoding: gzip on the
+// part. Other parts are left uncompressed.
+func (b *RequestBuilder) AddGzippedFormFile(field, filename string, r io.Reader) *RequestBuilder {
+	b.formParts = append(b.formParts, formPart{field: field, filename: filename, reader: r, gzip: true})
+	b.invalidateBody()
+	return b
+}
+
+func (b *RequestBuilder) marshalMultipart() ([]byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	for _, part := range b.formParts {
+		if part.reader == nil {
+			if err := w.WriteField(part.field, part.value); err != nil {
+				return nil, fmt.Errorf("unable to write form field %s: %v", part.field, err)
+			}
+			continue
+		}
+
+		header := textproto.MIMEHeader{}
+		header.Set(HeaderContentDisposition, fmt.Sprintf(`form-data; name="%s"; filename="%s"`,
+			escapeQuotes(part.field), escapeQuotes(part.filename)))
+		header.Set(HeaderContentType, MIMEApplicationOctetStream)
+		if part.gzip {
+			header.Set(HeaderContentEncoding, EncodingGzip)
+		}
+
+		pw, err := w.CreatePart(header)
+		if err != nil {
+			return nil, fmt.Errorf("unable to create form file %s: %v", part.field, err)
+		}
+
+		if part.gzip {
+			err = copyGzipped(pw, part.reader)
+		} else {
+			_, err = io.Copy(pw, part.reader)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to write form file %s: %v", part.field, err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("unable to write multipart body: %v", err)
+	}
+
+	b.SetHeader(HeaderContentType, w.FormDataContentType())
+	return buf.Bytes(), nil
+}
+
+func copyGzipped(w io.Writer, r io.Reader) error {
+	gw := gzip.NewWriter(w)
+	if _, err := io.Copy(gw, r); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
+
+// Part is a single part of a multipart response.
+type Part struct {
+	Header textproto.MIMEHeader
+	Body   []byte
+}
+
+// DoMultipart sends the request and splits a multipart response, such as multipart/mixed or multipart/related, into
+// its parts.
+func (b *RequestBuilder) DoMultipart(ctx context.Context, doer Doer) ([]Part, *http.Response, error) {
+	resp, err := b.DoStream(ctx, doer)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer drainAndClose(resp.Body)
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header.Get(HeaderContentType))
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse response media type: %v", err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		return nil, nil, fmt.Errorf("expected a multipart response, got %s", mediaType)
+	}
+
+	var parts []Part
+	r := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read multipart response: %v", err)
+		}
+
+		body, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unable to read multipart response: %v", err)
+		}
+		parts = append(parts, Part{Header: part.Header, Body: body})
+	}
+
+	return parts, resp, nil
+}
@@ -0,0 +1,93 @@
+package httprequest
+
+import (
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_AddGzippedFormFile(t *testing.T) {
+	original := strings.Repeat("some log line\n", 100)
+	req, err := New(http.MethodPost, testUrl, nil).
+		AddFormField("description", "nightly logs").
+		AddFormFile("readme", "README.txt", strings.NewReader("plain text")).
+		AddGzippedFormFile("logs", "app.log", strings.NewReader(original)).
+		Build(context.Background())
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(req.Header.Get(HeaderContentType))
+	require.NoError(t, err)
+	assert.Equal(t, "multipart/form-data", mediaType)
+
+	r := multipart.NewReader(req.Body, params["boundary"])
+
+	part, err := r.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "description", part.FormName())
+	value, err := ioutil.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "nightly logs", string(value))
+
+	part, err = r.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "readme", part.FormName())
+	assert.Empty(t, part.Header.Get(HeaderContentEncoding))
+	value, err = ioutil.ReadAll(part)
+	require.NoError(t, err)
+	assert.Equal(t, "plain text", string(value))
+
+	part, err = r.NextPart()
+	require.NoError(t, err)
+	assert.Equal(t, "logs", part.FormName())
+	assert.Equal(t, "app.log", part.FileName())
+	assert.Equal(t, "gzip", part.Header.Get(HeaderContentEncoding))
+	gr, err := gzip.NewReader(part)
+	require.NoError(t, err)
+	value, err = ioutil.ReadAll(gr)
+	require.NoError(t, err)
+	assert.Equal(t, original, string(value))
+}
+
+func TestRequestBuilder_DoMultipart(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mw := multipart.NewWriter(w)
+		w.Header().Set(HeaderContentType, "multipart/mixed; boundary="+mw.Boundary())
+
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{HeaderContentType: {MIMEApplicationJson}})
+		_, _ = pw.Write([]byte(`{"id":42}`))
+		pw, _ = mw.CreatePart(textproto.MIMEHeader{HeaderContentType: {"text/plain"}})
+		_, _ = pw.Write([]byte("hello"))
+		_ = mw.Close()
+	}))
+	defer server.Close()
+
+	parts, resp, err := New(http.MethodGet, server.URL, nil).DoMultipart(context.Background(), server.Client())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Len(t, parts, 2)
+	assert.Equal(t, MIMEApplicationJson, parts[0].Header.Get(HeaderContentType))
+	assert.Equal(t, `{"id":42}`, string(parts[0].Body))
+	assert.Equal(t, "text/plain", parts[1].Header.Get(HeaderContentType))
+	assert.Equal(t, "hello", string(parts[1].Body))
+}
+
+func TestRequestBuilder_DoMultipart_nonMultipartResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	_, _, err := New(http.MethodGet, server.URL, nil).DoMultipart(context.Background(), server.Client())
+	require.Error(t, err)
+}
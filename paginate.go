@@ -0,0 +1,99 @@
+package httprequest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// PageOptions configures how DoAll follows pages.
+type PageOptions struct {
+	// MaxPages caps the number of pages requested. Zero means no limit.
+	MaxPages int
+	// NextURL returns the URL of the page after resp, or an empty string on the last page. It defaults to following
+	// the Link header's rel="next" URL.
+	NextURL func(resp *http.Response) string
+}
+
+// DoAll sends the request and keeps following next-page links, decoding each page into a []T and collecting the items
+// of every page in order. If MaxPages is reached while more pages remain, the items collected so far are returned
+// along with an error.
+func DoAll[T any](ctx context.Context, b *RequestBuilder, doer Doer, opts PageOptions) ([]T, error) {
+	nextURL := opts.NextURL
+	if nextURL == nil {
+		nextURL = linkNextURL
+	}
+
+	var items []T
+	page := b
+	for pages := 1; ; pages++ {
+		if err := ctx.Err(); err != nil {
+			return items, err
+		}
+
+		var pageItems []T
+		resp, err := page.Do(ctx, doer, &pageItems)
+		if err != nil {
+			return items, err
+		}
+		items = append(items, pageItems...)
+
+		next := nextURL(resp)
+		if next == "" {
+			return items, nil
+		}
+		if opts.MaxPages > 0 && pages >= opts.MaxPages {
+			return items, fmt.Errorf("reached the maximum of %d pages with more pages remaining", opts.MaxPages)
+		}
+
+		// Relative links are resolved against the URL of the page they were found on
+		if resp.Request != nil && resp.Request.URL != nil {
+			if u, err := resp.Request.URL.Parse(next); err == nil {
+				next = u.String()
+			}
+		}
+
+		// The link is the complete URL of the next page, so neither a URL given to NewURL nor the first page's path and
+		// query parameters may be applied to it again
+		nextPage := *page
+		nextPage.url = next
+		nextPage.parsedURL = nil
+		nextPage.pathParams = nil
+		nextPage.query = nil
+		if nextPage.queryBody {
+			nextPage.queryBody = false
+			nextPage.body = nil
+			nextPage.bodyBytes = nil
+			nextPage.bodyCached = false
+		}
+		page = &nextPage
+	}
+}
+
+// linkNextURL returns the target of the rel="next" link in the response's Link header, if any.
+func linkNextURL(resp *http.Response) string {
+	for _, header := range resp.Header.Values(HeaderLink) {
+		for _, link := range strings.Split(header, ",") {
+			segments := strings.Split(link, ";")
+			target := strings.TrimSpace(segments[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+
+			for _, param := range segments[1:] {
+				key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(key, "rel") {
+					continue
+				}
+				for _, rel := range strings.Fields(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return strings.Trim(target, "<>")
+					}
+				}
+			}
+		}
+	}
+
+	return ""
+}
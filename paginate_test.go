@@ -0,0 +1,108 @@
+package httprequest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoAll(t *testing.T) {
+	pages := map[string][]UserResponse{
+		"1": {{ID: 1, Name: "jack"}, {ID: 2, Name: "stephen"}},
+		"2": {{ID: 3, Name: "sam"}},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Len(t, r.URL.Query()["page"], 1, r.URL.String())
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Set("Link", `</users?page=2>; rel="next", </users?page=2>; rel="last"`)
+		}
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(pages[page])
+	}))
+	defer server.Close()
+
+	t.Run("Items from every linked page are collected", func(t *testing.T) {
+		users, err := DoAll[UserResponse](context.Background(), New(http.MethodGet, server.URL+"/users?page=1", nil),
+			server.Client(), PageOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, append(pages["1"], pages["2"]...), users)
+	})
+	t.Run("Query parameters of the first page aren't added to the next", func(t *testing.T) {
+		users, err := DoAll[UserResponse](context.Background(), Get(server.URL+"/users").Query("page", "1"),
+			server.Client(), PageOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, append(pages["1"], pages["2"]...), users)
+	})
+	t.Run("Query body of the first page isn't added to the next", func(t *testing.T) {
+		users, err := DoAll[UserResponse](context.Background(), Get(server.URL+"/users").
+			Body(struct {
+				Page string `url:"page"`
+			}{Page: "1"}).
+			QueryBody(), server.Client(), PageOptions{})
+		require.NoError(t, err)
+		assert.Equal(t, append(pages["1"], pages["2"]...), users)
+	})
+	t.Run("Next link replaces a parsed URL", func(t *testing.T) {
+		u, err := url.Parse(server.URL + "/users?page=1")
+		require.NoError(t, err)
+
+		// MaxPages stops the test if page 1 is requested again instead of following the link
+		users, err := DoAll[UserResponse](context.Background(), NewURL(http.MethodGet, u, nil),
+			server.Client(), PageOptions{MaxPages: 3})
+		require.NoError(t, err)
+		assert.Equal(t, append(pages["1"], pages["2"]...), users)
+	})
+	t.Run("Max pages stops early with an error", func(t *testing.T) {
+		users, err := DoAll[UserResponse](context.Background(), New(http.MethodGet, server.URL+"/users?page=1", nil),
+			server.Client(), PageOptions{MaxPages: 1})
+		require.Error(t, err)
+		assert.Equal(t, pages["1"], users)
+	})
+	t.Run("Cancelled context stops paging", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := DoAll[UserResponse](ctx, New(http.MethodGet, server.URL+"/users?page=1", nil),
+			server.Client(), PageOptions{})
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+func Test_linkNextURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link []string
+		want string
+	}{
+		{
+			name: "Next link among several",
+			link: []string{`<https://example.com/?page=1>; rel="prev", <https://example.com/?page=3>; rel="next"`},
+			want: "https://example.com/?page=3",
+		},
+		{
+			name: "Next link in a multi-valued rel",
+			link: []string{`<https://example.com/?page=3>; rel="next last"`},
+			want: "https://example.com/?page=3",
+		},
+		{
+			name: "No next link",
+			link: []string{`<https://example.com/?page=1>; rel="prev"`},
+		},
+		{
+			name: "No link header",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{"Link": tt.link}}
+			assert.Equal(t, tt.want, linkNextURL(resp))
+		})
+	}
+}
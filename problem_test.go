@@ -0,0 +1,35 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblem(t *testing.T) {
+	ctx := context.Background()
+	problem := Problem{
+		Type:     "https://example.com/probs/out-of-credit",
+		Title:    "You do not have enough credit.",
+		Status:   http.StatusBadRequest,
+		Detail:   "Your current balance is 30, but that costs 50.",
+		Instance: "/account/12345/msgs/abc",
+	}
+	mock := httpmock.NewMock()
+	mock.GET(testUrl).
+		Return(http.StatusBadRequest, problem, nil).
+		AddHeader(HeaderContentType, MIMEApplicationProblemJson)
+
+	var out Problem
+	_, err := New(http.MethodGet, testUrl, nil).
+		StatusIs(http.StatusBadRequest).
+		Do(ctx, mock, &out)
+	require.NoError(t, err)
+	assert.Equal(t, problem, out)
+	assert.Equal(t, "400 You do not have enough credit.: Your current balance is 30, but that costs 50.", out.Error())
+	mock.AssertExpectations(t)
+}
@@ -0,0 +1,32 @@
+package httprequest
+
+import (
+	"io"
+)
+
+type ProgressFunc func(transferred, total int64)
+
+// progressReadCloser reports the cumulative number of bytes read from the underlying reader after every read.
+type progressReadCloser struct {
+	io.ReadCloser
+	total       int64
+	transferred int64
+	onProgress  ProgressFunc
+}
+
+func newProgressReadCloser(rc io.ReadCloser, total int64, onProgress ProgressFunc) *progressReadCloser {
+	return &progressReadCloser{
+		ReadCloser: rc,
+		total:      total,
+		onProgress: onProgress,
+	}
+}
+
+func (r *progressReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.transferred += int64(n)
+		r.onProgress(r.transferred, r.total)
+	}
+	return n, err
+}
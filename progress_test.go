@@ -0,0 +1,95 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_OnUploadProgress(t *testing.T) {
+	expectedBytes, err := json.Marshal(req1)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		assert.NoError(t, err)
+		assert.Equal(t, expectedBytes, body)
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_ = json.NewEncoder(w).Encode(resp1)
+	}))
+	defer server.Close()
+
+	var lastSent, lastTotal int64
+	var calls int
+	var out UserResponse
+	_, err = New(http.MethodPost, server.URL, req1).
+		OnUploadProgress(func(bytesSent, totalBytes int64) {
+			calls++
+			lastSent, lastTotal = bytesSent, totalBytes
+		}).
+		Do(context.Background(), server.Client(), &out)
+	require.NoError(t, err)
+	assert.NotZero(t, calls)
+	assert.Equal(t, int64(len(expectedBytes)), lastSent)
+	assert.Equal(t, int64(len(expectedBytes)), lastTotal)
+	assert.Equal(t, resp1, out)
+}
+
+func TestRequestBuilder_OnUploadProgress_preservesRequest(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 10000)
+
+	var lastSent int64
+	req, err := New(http.MethodPut, testUrl, payload).
+		Encoder("application/octet-stream", func(v interface{}) ([]byte, error) { return v.([]byte), nil }).
+		OnUploadProgress(func(bytesSent, _ int64) {
+			lastSent = bytesSent
+		}).
+		Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(payload)), req.ContentLength)
+	require.NotNil(t, req.GetBody)
+
+	body, err := ioutil.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, int64(len(payload)), lastSent)
+
+	// A body recreated for a redirect or retry reports its progress from the start
+	lastSent = 0
+	rc, err := req.GetBody()
+	require.NoError(t, err)
+	body, err = ioutil.ReadAll(rc)
+	require.NoError(t, err)
+	assert.Equal(t, payload, body)
+	assert.Equal(t, int64(len(payload)), lastSent)
+}
+
+func TestRequestBuilder_OnDownloadProgress(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 10000)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(payload)))
+		_, _ = w.Write(payload)
+	}))
+	defer server.Close()
+
+	var lastRead, lastTotal int64
+	var buf bytes.Buffer
+	n, _, err := New(http.MethodGet, server.URL, nil).
+		OnDownloadProgress(func(bytesRead, totalBytes int64) {
+			lastRead, lastTotal = bytesRead, totalBytes
+		}).
+		DoDownload(context.Background(), server.Client(), &buf)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(payload)), n)
+	assert.Equal(t, int64(len(payload)), lastRead)
+	assert.Equal(t, int64(len(payload)), lastTotal)
+	assert.Equal(t, payload, buf.Bytes())
+}
@@ -0,0 +1,140 @@
+package httprequest
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// QueryFrom adds query parameters from the fields of a struct tagged with `url:"name"`, in the style of
+// google/go-querystring. The omitempty option skips zero values, slice fields add one parameter per element, and
+// fields tagged "-" are ignored. Untagged fields use the field name. Times are encoded in RFC 3339 format.
+func (b *RequestBuilder) QueryFrom(v interface{}) *RequestBuilder {
+	values, err := queryValues(v)
+	if err != nil {
+		b.err = err
+		return b
+	}
+
+	for key, vals := range values {
+		for _, val := range vals {
+			b.Query(key, val)
+		}
+	}
+	return b
+}
+
+// QueryBody sends the builder's body as query parameters instead of in the request body, encoded like QueryFrom. It
+// suits GET endpoints that take complex filters as a struct.
+func (b *RequestBuilder) QueryBody() *RequestBuilder {
+	b.queryBody = true
+	return b
+}
+
+// bodyQuery returns the query parameters encoded from the body when QueryBody is set.
+func (b *RequestBuilder) bodyQuery() (url.Values, error) {
+	if !b.queryBody || b.body == nil {
+		return nil, nil
+	}
+
+	return queryValues(b.body)
+}
+
+func queryValues(v interface{}) (url.Values, error) {
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return url.Values{}, nil
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable to encode query: expected a struct, got %s", rv.Kind())
+	}
+
+	values := url.Values{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag := field.Tag.Get("url")
+		if tag == "-" {
+			continue
+		}
+		opts := strings.Split(tag, ",")
+		name := opts[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		fv := rv.Field(i)
+		if hasOption(opts[1:], "omitempty") && fv.IsZero() {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice || fv.Kind() == reflect.Array {
+			for j := 0; j < fv.Len(); j++ {
+				val, err := queryValue(fv.Index(j))
+				if err != nil {
+					return nil, fmt.Errorf("unable to encode query field %s: %v", field.Name, err)
+				}
+				values.Add(name, val)
+			}
+			continue
+		}
+
+		val, err := queryValue(fv)
+		if err != nil {
+			return nil, fmt.Errorf("unable to encode query field %s: %v", field.Name, err)
+		}
+		values.Add(name, val)
+	}
+
+	return values, nil
+}
+
+func hasOption(opts []string, option string) bool {
+	for _, opt := range opts {
+		if opt == option {
+			return true
+		}
+	}
+	return false
+}
+
+func queryValue(v reflect.Value) (string, error) {
+	// A nil pointer can't be dereferenced by String, even when its type implements fmt.Stringer
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return "", nil
+	}
+
+	switch val := v.Interface().(type) {
+	case time.Time:
+		return val.Format(time.RFC3339), nil
+	case fmt.Stringer:
+		return val.String(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, 64), nil
+	case reflect.Ptr:
+		return queryValue(v.Elem())
+	default:
+		return "", fmt.Errorf("unsupported kind %s", v.Kind())
+	}
+}
@@ -0,0 +1,92 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type UserFilter struct {
+	Name     string   `url:"name"`
+	Limit    int      `url:"limit,omitempty"`
+	Offset   int      `url:"offset,omitempty"`
+	IsAdmin  bool     `url:"is_admin"`
+	Roles    []string `url:"role"`
+	Internal string   `url:"-"`
+}
+
+func TestRequestBuilder_QueryFrom(t *testing.T) {
+	t.Run("Struct fields are encoded as query parameters", func(t *testing.T) {
+		req, err := New(http.MethodGet, testUrl+"?version=2", nil).
+			QueryFrom(UserFilter{
+				Name:     "jack ramey",
+				Limit:    10,
+				IsAdmin:  true,
+				Roles:    []string{"owner", "editor"},
+				Internal: "secret",
+			}).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "is_admin=true&limit=10&name=jack+ramey&role=owner&role=editor&version=2", req.URL.RawQuery)
+	})
+	t.Run("Times are encoded as RFC 3339 and nil pointers as empty values", func(t *testing.T) {
+		since := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+		req, err := New(http.MethodGet, testUrl, nil).
+			QueryFrom(struct {
+				Since  time.Time  `url:"since"`
+				Until  *time.Time `url:"until"`
+				Before *time.Time `url:"before,omitempty"`
+			}{Since: since}).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "since=2024-03-01T12%3A30%3A00Z&until=", req.URL.RawQuery)
+	})
+	t.Run("Omitempty is recognised alongside other options", func(t *testing.T) {
+		req, err := New(http.MethodGet, testUrl, nil).
+			QueryFrom(struct {
+				Name  string `url:"name,omitempty,comma"`
+				Limit int    `url:"limit,comma,omitempty"`
+			}{}).
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Empty(t, req.URL.RawQuery)
+	})
+	t.Run("Non-struct value returns an error from Build", func(t *testing.T) {
+		_, err := New(http.MethodGet, testUrl, nil).
+			QueryFrom("name=jack").
+			Build(context.Background())
+		require.Error(t, err)
+	})
+}
+
+func TestRequestBuilder_Query(t *testing.T) {
+	req, err := New(http.MethodGet, testUrl+"?version=2", nil).
+		Query("name", "jack").
+		Query("role", "owner").
+		Query("role", "editor").
+		Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "name=jack&role=owner&role=editor&version=2", req.URL.RawQuery)
+}
+
+func TestRequestBuilder_QueryBody(t *testing.T) {
+	t.Run("Body is sent as query parameters", func(t *testing.T) {
+		req, err := New(http.MethodGet, testUrl, UserFilter{Name: "jack", Limit: 10, Roles: []string{"owner"}}).
+			QueryBody().
+			Build(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, "is_admin=false&limit=10&name=jack&role=owner", req.URL.RawQuery)
+		assert.Equal(t, http.NoBody, req.Body)
+		assert.Equal(t, int64(0), req.ContentLength)
+	})
+	t.Run("Non-struct body returns an error from Build", func(t *testing.T) {
+		_, err := New(http.MethodGet, testUrl, []string{"jack"}).
+			QueryBody().
+			Build(context.Background())
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,192 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var retryableStatusCodes = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// defaultRetryJitter is the fraction by which backoffs are randomized unless RetryJitter says otherwise.
+const defaultRetryJitter = 0.1
+
+// defaultMaxRetryAfter caps how long a retry waits for a 429's Retry-After unless MaxRetryAfter says otherwise.
+const defaultMaxRetryAfter = time.Minute
+
+// Retry makes up to maxAttempts attempts at the request, waiting backoff before the first retry and doubling the wait
+// after every subsequent attempt. Responses with a 429, 502, 503, or 504 status and TLS handshake timeouts are retried.
+// A 502, 503 or 504 may come after the server acted on the request, so for methods that aren't idempotent, such as
+// POST and PATCH, those are only retried with an IdempotencyKey or RetryNonIdempotent. Each wait is randomized by up
+// to 10% so that clients failing together don't retry together, see RetryJitter. A 429 with a Retry-After header
+// waits as long as the server asks instead, see MaxRetryAfter.
+func (b *RequestBuilder) Retry(maxAttempts int, backoff time.Duration) *RequestBuilder {
+	b.retryMaxAttempts = maxAttempts
+	b.retryBackoff = backoff
+	return b
+}
+
+// IdempotencyKey sets the Idempotency-Key header, letting the server recognize retried attempts as the same operation.
+// The key is set once on the builder, so every retry attempt carries the same value. Retrying a non-idempotent method
+// without a key logs a warning when a Logger is configured.
+func (b *RequestBuilder) IdempotencyKey(key string) *RequestBuilder {
+	return b.SetHeader(HeaderIdempotencyKey, key)
+}
+
+// RetryNonIdempotent lets Retry retry methods that aren't idempotent on a 502, 503 or 504 without an IdempotencyKey,
+// for endpoints that are known to be safe to repeat.
+func (b *RequestBuilder) RetryNonIdempotent() *RequestBuilder {
+	b.retryNonIdempotent = true
+	return b
+}
+
+// RetryJitter randomizes each retry backoff by up to the given fraction in either direction, so a fraction of 0.25
+// turns a 1s backoff into a wait between 750ms and 1.25s. A fraction of 0 disables jitter.
+func (b *RequestBuilder) RetryJitter(fraction float64) *RequestBuilder {
+	b.retryJitter = fraction
+	b.retryJitterSet = true
+	return b
+}
+
+// MaxRetryAfter caps how long a retry waits when a 429 response's Retry-After header asks for longer. It defaults to a
+// minute.
+func (b *RequestBuilder) MaxRetryAfter(d time.Duration) *RequestBuilder {
+	b.maxRetryAfter = d
+	return b
+}
+
+// retryAfter returns how long the response asks to wait before retrying, capped by the builder's maximum. Only 429
+// responses are considered. Retry-After is either a number of seconds or an HTTP date.
+func (b *RequestBuilder) retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	if resp == nil || resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+
+	value := strings.TrimSpace(resp.Header.Get(HeaderRetryAfter))
+	if value == "" {
+		return 0, false
+	}
+
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if date, err := http.ParseTime(value); err == nil {
+		wait = date.Sub(now)
+	} else {
+		return 0, false
+	}
+
+	maxWait := defaultMaxRetryAfter
+	if b.maxRetryAfter > 0 {
+		maxWait = b.maxRetryAfter
+	}
+	if wait > maxWait {
+		wait = maxWait
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// jitter randomizes d according to the builder's retry jitter.
+func (b *RequestBuilder) jitter(d time.Duration) time.Duration {
+	fraction := defaultRetryJitter
+	if b.retryJitterSet {
+		fraction = b.retryJitter
+	}
+	if fraction <= 0 {
+		return d
+	}
+
+	random := rand.Float64
+	if b.retryRand != nil {
+		random = b.retryRand.Float64
+	}
+
+	return time.Duration(float64(d) * (1 + fraction*(2*random()-1)))
+}
+
+func (b *RequestBuilder) sendWithRetry(ctx context.Context, doer Doer) (*http.Response, error) {
+	backoff := b.retryBackoff
+	for attempt := 1; ; attempt++ {
+		recordAttempt(ctx, attempt)
+		resp, err := b.sendOnce(ctx, doer)
+		if attempt >= b.retryMaxAttempts || !b.isRetryable(resp, err) {
+			return resp, err
+		}
+		if attempt == 1 && b.logger != nil && !isIdempotentMethod(b.httpMethod) && b.header.Get(HeaderIdempotencyKey) == "" {
+			b.logger.Logf("retrying %s %s without an idempotency key may repeat its side effects", b.httpMethod, b.resolveURL())
+		}
+
+		wait, ok := b.retryAfter(resp, time.Now())
+		if !ok {
+			wait = b.jitter(backoff)
+		}
+
+		// Drain the discarded response so its connection can be reused by the next attempt
+		if resp != nil {
+			drainAndClose(resp.Body)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+func (b *RequestBuilder) isRetryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return isTLSHandshakeTimeout(err)
+	}
+	if !retryableStatusCodes[resp.StatusCode] {
+		return false
+	}
+
+	// A 429 means the request was turned away, while a gateway error can't tell whether it was carried out
+	if resp.StatusCode == http.StatusTooManyRequests || isIdempotentMethod(b.httpMethod) {
+		return true
+	}
+	return b.retryNonIdempotent || b.header.Get(HeaderIdempotencyKey) != ""
+}
+
+// tlsHandshakeTimeoutMessage is the message of the error net/http's transport returns when TLSHandshakeTimeout passes.
+// The error's type is unexported, so its message is the only way to tell it apart from other timeouts.
+const tlsHandshakeTimeoutMessage = "net/http: TLS handshake timeout"
+
+// isTLSHandshakeTimeout reports whether err is the transport's TLS handshake timeout. The request never reached the
+// server in that case, so it is safe to retry regardless of the method. Each error in the chain is checked on its
+// own, as wrappers such as url.Error include the URL in their message.
+func isTLSHandshakeTimeout(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		netErr, ok := err.(net.Error)
+		if ok && netErr.Timeout() && err.Error() == tlsHandshakeTimeoutMessage {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,374 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// handshakeTimeoutError mirrors the error net/http's transport returns when a TLS handshake times out.
+type handshakeTimeoutError struct{}
+
+func (handshakeTimeoutError) Timeout() bool   { return true }
+func (handshakeTimeoutError) Temporary() bool { return true }
+func (handshakeTimeoutError) Error() string   { return "net/http: TLS handshake timeout" }
+
+// timeoutError is a generic timeout unrelated to the TLS handshake.
+type timeoutError struct{}
+
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+func (timeoutError) Error() string   { return "i/o timeout" }
+
+func TestRequestBuilder_Retry(t *testing.T) {
+	t.Run("Retryable status is retried", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusServiceUnavailable, nil, nil).Once()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil).Once()
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			Retry(3, time.Millisecond).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Retry observes a sequence of responses", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).ReturnSequence(
+			httpmock.MockResponse{StatusCode: http.StatusServiceUnavailable},
+			httpmock.MockResponse{StatusCode: http.StatusOK, Body: resp1},
+		).Times(2)
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			Retry(3, time.Millisecond).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Len(t, mock.Requests(), 2)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Gives up after the max attempts", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusServiceUnavailable, nil, nil).Times(2)
+
+		_, err := New(http.MethodGet, testUrl, nil).
+			Retry(2, time.Millisecond).
+			Do(ctx, mock, nil)
+		require.Error(t, err)
+		mock.AssertExpectations(t)
+	})
+	t.Run("TLS handshake timeout is retried", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil).Once()
+
+		var attempts int
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			if attempts == 1 {
+				return nil, &url.Error{Op: "Get", URL: testUrl, Err: handshakeTimeoutError{}}
+			}
+			return mock.Do(req)
+		})
+
+		var out UserResponse
+		_, err := New(http.MethodGet, testUrl, nil).
+			Retry(3, time.Millisecond).
+			Do(ctx, doer, &out)
+		require.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.Equal(t, resp1, out)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Other timeouts are not retried", func(t *testing.T) {
+		var attempts int
+		doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return nil, &url.Error{Op: "Get", URL: testUrl, Err: timeoutError{}}
+		})
+
+		_, err := New(http.MethodGet, testUrl, nil).
+			Retry(3, time.Millisecond).
+			Do(context.Background(), doer, nil)
+		require.Error(t, err)
+		assert.Equal(t, 1, attempts)
+	})
+	t.Run("Timeouts mentioning a handshake elsewhere are not retried", func(t *testing.T) {
+		for _, err := range []error{
+			&url.Error{Op: "Get", URL: "https://example.com/handshake", Err: timeoutError{}},
+			&url.Error{Op: "Get", URL: testUrl, Err: errors.New("remote error: tls: handshake failure")},
+		} {
+			var attempts int
+			doer := DoerFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				return nil, err
+			})
+
+			_, sendErr := New(http.MethodGet, testUrl, nil).
+				Retry(3, time.Millisecond).
+				Do(context.Background(), doer, nil)
+			require.Error(t, sendErr)
+			assert.Equal(t, 1, attempts, err.Error())
+		}
+	})
+	t.Run("Handshake timeout from the transport is retried", func(t *testing.T) {
+		// The listener accepts connections but never answers the TLS handshake
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+		var accepted int32
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				atomic.AddInt32(&accepted, 1)
+				defer conn.Close()
+			}
+		}()
+
+		client := &http.Client{Transport: &http.Transport{TLSHandshakeTimeout: 20 * time.Millisecond}}
+		_, err = New(http.MethodGet, "https://"+listener.Addr().String(), nil).
+			Retry(2, time.Millisecond).
+			Do(context.Background(), client, nil)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "TLS handshake timeout")
+		assert.Equal(t, int32(2), atomic.LoadInt32(&accepted))
+	})
+	t.Run("Connection resets are not retried", func(t *testing.T) {
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).ReturnConnReset().Once()
+
+		_, err := New(http.MethodGet, testUrl, nil).
+			Retry(3, time.Millisecond).
+			Do(context.Background(), mock, nil)
+		assert.True(t, errors.Is(err, syscall.ECONNRESET))
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestRequestBuilder_Retry_resendsIdenticalBody(t *testing.T) {
+	ctx := context.Background()
+	mock := httpmock.NewMock()
+	mock.POST(testUrl, req1).Return(http.StatusServiceUnavailable, nil, nil).Once()
+	mock.POST(testUrl, req1).Return(http.StatusOK, resp1, nil).Once()
+
+	var out UserResponse
+	_, err := New(http.MethodPost, testUrl, req1).
+		Retry(2, time.Millisecond).
+		RetryNonIdempotent().
+		Do(ctx, mock, &out)
+	require.NoError(t, err)
+	mock.AssertExpectations(t)
+
+	requests := mock.Requests()
+	require.Len(t, requests, 2)
+	assert.NotEmpty(t, requests[0].Body)
+	assert.Equal(t, requests[0].Body, requests[1].Body)
+}
+
+func TestRequestBuilder_RetryNonIdempotent(t *testing.T) {
+	t.Run("POST isn't retried on a gateway error by default", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusBadGateway, nil, nil).Once()
+
+		_, err := New(http.MethodPost, testUrl, req1).
+			Retry(3, time.Millisecond).
+			Do(ctx, mock, nil)
+		require.Error(t, err)
+		mock.AssertExpectations(t)
+		assert.Len(t, mock.Requests(), 1)
+	})
+	t.Run("POST is retried on a 429 by default", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusTooManyRequests, nil, nil).Once()
+		mock.POST(testUrl, req1).Return(http.StatusOK, resp1, nil).Once()
+
+		var out UserResponse
+		_, err := New(http.MethodPost, testUrl, req1).
+			Retry(2, time.Millisecond).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		mock.AssertExpectations(t)
+		assert.Equal(t, resp1, out)
+	})
+	t.Run("Opting in retries a PATCH on a gateway error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.Expect(httpmock.MatchOn{HttpMethod: http.MethodPatch, Header: http.Header{HeaderContentType: {MIMEApplicationJson}}, Body: req1}).
+			ReturnSequence(
+				httpmock.MockResponse{StatusCode: http.StatusGatewayTimeout},
+				httpmock.MockResponse{StatusCode: http.StatusOK, Body: resp1},
+			).Times(2)
+
+		var out UserResponse
+		_, err := New(http.MethodPatch, testUrl, req1).
+			Retry(2, time.Millisecond).
+			RetryNonIdempotent().
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		mock.AssertExpectations(t)
+		assert.Equal(t, resp1, out)
+	})
+}
+
+func TestRequestBuilder_IdempotencyKey(t *testing.T) {
+	t.Run("Key is stable across retries", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).WithMatchHeader(HeaderIdempotencyKey, "payment-42").ReturnSequence(
+			httpmock.MockResponse{StatusCode: http.StatusServiceUnavailable},
+			httpmock.MockResponse{StatusCode: http.StatusOK, Body: resp1},
+		).Times(2)
+
+		var out UserResponse
+		_, err := New(http.MethodPost, testUrl, req1).
+			IdempotencyKey("payment-42").
+			Retry(2, time.Millisecond).
+			Do(ctx, mock, &out)
+		require.NoError(t, err)
+		mock.AssertExpectations(t)
+
+		requests := mock.Requests()
+		require.Len(t, requests, 2)
+		for _, recorded := range requests {
+			assert.Equal(t, "payment-42", recorded.Request.Header.Get(HeaderIdempotencyKey))
+		}
+	})
+	t.Run("Retrying a POST without a key logs a warning", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).ReturnSequence(
+			httpmock.MockResponse{StatusCode: http.StatusServiceUnavailable},
+			httpmock.MockResponse{StatusCode: http.StatusOK, Body: resp1},
+		).Times(2)
+		logger := &capturingLogger{}
+
+		_, err := New(http.MethodPost, testUrl, req1).
+			Retry(2, time.Millisecond).
+			RetryNonIdempotent().
+			WithLogger(logger).
+			Do(ctx, mock, nil)
+		require.NoError(t, err)
+		require.Len(t, logger.lines, 2)
+		assert.Contains(t, logger.lines[0], "without an idempotency key")
+		mock.AssertExpectations(t)
+	})
+	t.Run("POST that isn't retried logs no warning", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.POST(testUrl, req1).Return(http.StatusOK, resp1, nil)
+		logger := &capturingLogger{}
+
+		_, err := New(http.MethodPost, testUrl, req1).
+			Retry(2, time.Millisecond).
+			WithLogger(logger).
+			Do(ctx, mock, nil)
+		require.NoError(t, err)
+		require.Len(t, logger.lines, 1)
+		assert.NotContains(t, logger.lines[0], "without an idempotency key")
+	})
+}
+
+func TestRequestBuilder_RetryJitter(t *testing.T) {
+	t.Run("Backoffs fall within the jittered bounds", func(t *testing.T) {
+		b := New(http.MethodGet, testUrl, nil).Retry(5, time.Second).RetryJitter(0.25)
+		b.retryRand = rand.New(rand.NewSource(42))
+
+		backoffs := map[time.Duration]bool{}
+		for i := 0; i < 20; i++ {
+			backoff := b.jitter(time.Second)
+			assert.GreaterOrEqual(t, backoff, 750*time.Millisecond)
+			assert.LessOrEqual(t, backoff, 1250*time.Millisecond)
+			backoffs[backoff] = true
+		}
+		assert.Greater(t, len(backoffs), 1, "expected the backoffs to vary")
+	})
+	t.Run("Default jitter is applied", func(t *testing.T) {
+		b := New(http.MethodGet, testUrl, nil).Retry(5, time.Second)
+		b.retryRand = rand.New(rand.NewSource(42))
+
+		backoff := b.jitter(time.Second)
+		assert.GreaterOrEqual(t, backoff, 900*time.Millisecond)
+		assert.LessOrEqual(t, backoff, 1100*time.Millisecond)
+		assert.NotEqual(t, time.Second, backoff)
+	})
+	t.Run("Zero fraction disables jitter", func(t *testing.T) {
+		b := New(http.MethodGet, testUrl, nil).Retry(5, time.Second).RetryJitter(0)
+		assert.Equal(t, time.Second, b.jitter(time.Second))
+	})
+}
+
+func TestRequestBuilder_retryAfter(t *testing.T) {
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	newResponse := func(status int, retryAfter string) *http.Response {
+		resp := &http.Response{StatusCode: status, Header: http.Header{}}
+		if retryAfter != "" {
+			resp.Header.Set(HeaderRetryAfter, retryAfter)
+		}
+		return resp
+	}
+
+	tests := []struct {
+		name     string
+		resp     *http.Response
+		max      time.Duration
+		wantWait time.Duration
+		wantOk   bool
+	}{
+		{"Seconds", newResponse(http.StatusTooManyRequests, "2"), 0, 2 * time.Second, true},
+		{"HTTP date", newResponse(http.StatusTooManyRequests, "Fri, 01 Mar 2024 12:00:30 GMT"), 0, 30 * time.Second, true},
+		{"Date in the past", newResponse(http.StatusTooManyRequests, "Fri, 01 Mar 2024 11:59:00 GMT"), 0, 0, true},
+		{"Capped by the default maximum", newResponse(http.StatusTooManyRequests, "3600"), 0, defaultMaxRetryAfter, true},
+		{"Capped by the configured maximum", newResponse(http.StatusTooManyRequests, "10"), 5 * time.Second, 5 * time.Second, true},
+		{"Invalid value", newResponse(http.StatusTooManyRequests, "soon"), 0, 0, false},
+		{"Missing header", newResponse(http.StatusTooManyRequests, ""), 0, 0, false},
+		{"Other status", newResponse(http.StatusServiceUnavailable, "2"), 0, 0, false},
+		{"No response", nil, 0, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wait, ok := New(http.MethodGet, testUrl, nil).MaxRetryAfter(tt.max).retryAfter(tt.resp, now)
+			assert.Equal(t, tt.wantOk, ok)
+			assert.Equal(t, tt.wantWait, wait)
+		})
+	}
+}
+
+func TestRequestBuilder_Retry_tooManyRequests(t *testing.T) {
+	mock := httpmock.NewMock()
+	mock.GET(testUrl).Return(http.StatusTooManyRequests, nil, nil).AddHeader(HeaderRetryAfter, "60").Once()
+	mock.GET(testUrl).Return(http.StatusOK, resp1, nil).Once()
+
+	// The configured backoff would wait an hour, so finishing quickly shows the capped Retry-After was used instead
+	start := time.Now()
+	var out UserResponse
+	_, err := New(http.MethodGet, testUrl, nil).
+		Retry(2, time.Hour).
+		MaxRetryAfter(10*time.Millisecond).
+		Do(context.Background(), mock, &out)
+	require.NoError(t, err)
+	assert.Equal(t, resp1, out)
+	assert.Less(t, time.Since(start), time.Second)
+	mock.AssertExpectations(t)
+}
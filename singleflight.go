@@ -0,0 +1,97 @@
+package httprequest
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// SingleFlight is a Doer that coalesces concurrent identical GET requests, identified by their URL, so that only the
+// first is sent and every caller waiting on it receives its own copy of the response. Requests are coalesced
+// regardless of their headers, so don't share a SingleFlight between callers whose responses depend on headers such
+// as Authorization. A caller whose context is done stops waiting without affecting the others. Other methods are passed
+// through to the wrapped Doer.
+type SingleFlight struct {
+	doer Doer
+
+	mu      sync.Mutex
+	flights map[string]*flight
+}
+
+// flight is a request in progress, whose response is shared by every caller that joined it.
+type flight struct {
+	done chan struct{}
+	resp *http.Response
+	body []byte
+	err  error
+	// canceled is set when the request failed because the context of the caller that sent it was done, an error that
+	// belongs to that caller alone
+	canceled bool
+}
+
+func NewSingleFlight(doer Doer) *SingleFlight {
+	return &SingleFlight{
+		doer:    doer,
+		flights: map[string]*flight{},
+	}
+}
+
+func (s *SingleFlight) Do(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return s.doer.Do(req)
+	}
+
+	key := req.Method + " " + req.URL.String()
+	s.mu.Lock()
+	if f, ok := s.flights[key]; ok {
+		s.mu.Unlock()
+
+		select {
+		case <-f.done:
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		}
+
+		// The caller that sent the request gave up on it, so this one sends it again rather than share its error
+		if f.canceled {
+			return s.Do(req)
+		}
+		return f.response(req)
+	}
+	f := &flight{done: make(chan struct{})}
+	s.flights[key] = f
+	s.mu.Unlock()
+
+	f.resp, f.err = s.doer.Do(req)
+	if f.err == nil {
+		f.body, f.err = ioutil.ReadAll(f.resp.Body)
+		_ = f.resp.Body.Close()
+		if f.err != nil {
+			f.err = fmt.Errorf("unable to read response body: %w", f.err)
+		}
+	}
+
+	f.canceled = f.err != nil && req.Context().Err() != nil
+
+	s.mu.Lock()
+	delete(s.flights, key)
+	s.mu.Unlock()
+	close(f.done)
+
+	return f.response(req)
+}
+
+// response returns a copy of the shared response with its own body, tied to the caller's request.
+func (f *flight) response(req *http.Request) (*http.Response, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	resp := *f.resp
+	resp.Header = f.resp.Header.Clone()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(f.body))
+	resp.Request = req
+	return &resp, nil
+}
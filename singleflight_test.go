@@ -0,0 +1,155 @@
+package httprequest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// joinContext signals on joined the first time Done is called. The backends in these tests never call it, so it
+// marks the moment a SingleFlight caller starts waiting on the request in flight.
+type joinContext struct {
+	context.Context
+	once   sync.Once
+	joined chan struct{}
+}
+
+func newJoinContext(ctx context.Context) *joinContext {
+	return &joinContext{Context: ctx, joined: make(chan struct{})}
+}
+
+func (c *joinContext) Done() <-chan struct{} {
+	c.once.Do(func() { close(c.joined) })
+	return c.Context.Done()
+}
+
+func TestSingleFlight(t *testing.T) {
+	body, err := json.Marshal(resp1)
+	require.NoError(t, err)
+	respond := func() *http.Response {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{HeaderContentType: {MIMEApplicationJson}},
+			Body:       io.NopCloser(bytes.NewReader(body)),
+		}
+	}
+	get := func(t *testing.T, doer Doer, ctx context.Context) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, testUrl, nil)
+		require.NoError(t, err)
+		return doer.Do(req)
+	}
+	readBody := func(t *testing.T, resp *http.Response) []byte {
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return data
+	}
+
+	t.Run("Concurrent requests share one response", func(t *testing.T) {
+		const waiters = 9
+		var hits int32
+		release := make(chan struct{})
+		doer := NewSingleFlight(DoerFunc(func(req *http.Request) (*http.Response, error) {
+			atomic.AddInt32(&hits, 1)
+			<-release
+			return respond(), nil
+		}))
+
+		var done sync.WaitGroup
+		bodies := make([][]byte, waiters+1)
+		errs := make([]error, waiters+1)
+		call := func(i int, ctx context.Context) {
+			defer done.Done()
+			resp, err := get(t, doer, ctx)
+			errs[i] = err
+			if err == nil {
+				bodies[i] = readBody(t, resp)
+			}
+		}
+
+		done.Add(1)
+		go call(0, context.Background())
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&hits) == 1 }, time.Second, time.Millisecond)
+
+		// Hold the backend until every other caller has joined the request in flight
+		for i := 1; i <= waiters; i++ {
+			ctx := newJoinContext(context.Background())
+			done.Add(1)
+			go call(i, ctx)
+			<-ctx.joined
+		}
+		close(release)
+		done.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&hits))
+		for i := range errs {
+			require.NoError(t, errs[i])
+			assert.Equal(t, body, bodies[i])
+		}
+	})
+	t.Run("Cancelled waiter stops waiting", func(t *testing.T) {
+		release := make(chan struct{})
+		defer close(release)
+		sent := make(chan struct{})
+		doer := NewSingleFlight(DoerFunc(func(req *http.Request) (*http.Response, error) {
+			close(sent)
+			<-release
+			return respond(), nil
+		}))
+
+		go func() { _, _ = get(t, doer, context.Background()) }()
+		<-sent
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		_, err := get(t, doer, ctx)
+		assert.True(t, errors.Is(err, context.DeadlineExceeded), "expected a deadline error, got %v", err)
+	})
+	t.Run("Cancelled sender doesn't fail the waiters", func(t *testing.T) {
+		var hits int32
+		doer := NewSingleFlight(DoerFunc(func(req *http.Request) (*http.Response, error) {
+			// The first request is held until its sender gives up on it
+			if atomic.AddInt32(&hits, 1) == 1 {
+				<-req.Context().Done()
+				return nil, req.Context().Err()
+			}
+			return respond(), nil
+		}))
+
+		senderCtx, cancel := context.WithCancel(context.Background())
+		senderErr := make(chan error, 1)
+		go func() {
+			_, err := get(t, doer, senderCtx)
+			senderErr <- err
+		}()
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&hits) == 1 }, time.Second, time.Millisecond)
+
+		waiterCtx := newJoinContext(context.Background())
+		type result struct {
+			resp *http.Response
+			err  error
+		}
+		waiter := make(chan result, 1)
+		go func() {
+			resp, err := get(t, doer, waiterCtx)
+			waiter <- result{resp, err}
+		}()
+		<-waiterCtx.joined
+		cancel()
+
+		assert.True(t, errors.Is(<-senderErr, context.Canceled))
+		res := <-waiter
+		require.NoError(t, res.err)
+		assert.Equal(t, body, readBody(t, res.resp))
+		assert.Equal(t, int32(2), atomic.LoadInt32(&hits))
+	})
+}
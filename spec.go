@@ -0,0 +1,55 @@
+package httprequest
+
+import (
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var pathParamPattern = regexp.MustCompile(`{([^{}/]+)}`)
+
+// OperationSpec is a machine-readable description of a configured builder, suitable as input for client code
+// generation.
+type OperationSpec struct {
+	Method              string   `json:"method"`
+	PathTemplate        string   `json:"pathTemplate"`
+	PathParams          []string `json:"pathParams,omitempty"`
+	ExpectedStatuses    []int    `json:"expectedStatuses"`
+	RequestContentType  string   `json:"requestContentType,omitempty"`
+	ResponseContentType string   `json:"responseContentType"`
+	RequiredHeaders     []string `json:"requiredHeaders,omitempty"`
+}
+
+// Spec describes the operation the builder is configured for. The path template is the unresolved URL path, so path
+// parameters appear as {name} placeholders.
+func (b *RequestBuilder) Spec() OperationSpec {
+	spec := OperationSpec{
+		Method:              b.httpMethod,
+		PathTemplate:        b.url,
+		ExpectedStatuses:    append([]int(nil), b.expectedStatusCodes...),
+		ResponseContentType: b.contentType,
+	}
+
+	// The template is parsed with the placeholders escaped so that they survive as-is in the path
+	if u, err := url.Parse(b.url); err == nil {
+		spec.PathTemplate = strings.NewReplacer("%7B", "{", "%7D", "}").Replace(u.EscapedPath())
+	}
+
+	for _, match := range pathParamPattern.FindAllStringSubmatch(spec.PathTemplate, -1) {
+		spec.PathParams = append(spec.PathParams, match[1])
+	}
+
+	if b.body != nil {
+		spec.RequestContentType = b.contentType
+	}
+
+	for key := range b.header {
+		if key != HeaderContentType {
+			spec.RequiredHeaders = append(spec.RequiredHeaders, key)
+		}
+	}
+	sort.Strings(spec.RequiredHeaders)
+
+	return spec
+}
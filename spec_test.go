@@ -0,0 +1,34 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_Spec(t *testing.T) {
+	builder := New(http.MethodPut, "https://example.com/api/v1/orgs/{orgID}/users/{userID}", req1).
+		ContentType(MIMEApplicationXml).
+		StatusIn([]int{http.StatusOK, http.StatusCreated}).
+		SetHeader(HeaderAuthorization, "Bearer token").
+		SetHeader("X-Request-Id", "abc").
+		PathParam("orgID", "acme").
+		PathParam("userID", "42")
+
+	assert.Equal(t, OperationSpec{
+		Method:              http.MethodPut,
+		PathTemplate:        "/api/v1/orgs/{orgID}/users/{userID}",
+		PathParams:          []string{"orgID", "userID"},
+		ExpectedStatuses:    []int{http.StatusOK, http.StatusCreated},
+		RequestContentType:  MIMEApplicationXml,
+		ResponseContentType: MIMEApplicationXml,
+		RequiredHeaders:     []string{HeaderAuthorization, "X-Request-Id"},
+	}, builder.Spec())
+
+	req, err := builder.Build(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v1/orgs/acme/users/42", req.URL.Path)
+}
@@ -0,0 +1,50 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Stats describes a single call made with DoTimed.
+type Stats struct {
+	// Duration is the time from sending the first attempt to decoding the response
+	Duration time.Duration
+	// Attempts is the number of times the request was sent, which is more than one only when retries fired
+	Attempts int
+	// BytesRead is the number of bytes read from the response body as received, before any decompression
+	BytesRead int64
+}
+
+type statsKey struct{}
+
+// DoTimed behaves like Do but also reports how long the call took, how many attempts it made, and how much of the
+// response body was read, for lightweight per-call metrics. Stats are filled in even when an error is returned.
+func (b *RequestBuilder) DoTimed(ctx context.Context, doer Doer, out interface{}) (*http.Response, Stats, error) {
+	stats := &Stats{}
+	ctx = context.WithValue(ctx, statsKey{}, stats)
+
+	start := time.Now()
+	resp, err := b.Do(ctx, doer, out)
+	stats.Duration = time.Since(start)
+	return resp, *stats, err
+}
+
+// recordAttempt notes the number of attempts made so far on the Stats carried by the context, if any.
+func recordAttempt(ctx context.Context, attempt int) {
+	if stats, ok := ctx.Value(statsKey{}).(*Stats); ok {
+		stats.Attempts = attempt
+	}
+}
+
+// countBytesRead wraps the response body so that reads are counted on the Stats carried by the context, if any.
+func countBytesRead(ctx context.Context, resp *http.Response) {
+	stats, ok := ctx.Value(statsKey{}).(*Stats)
+	if !ok {
+		return
+	}
+
+	resp.Body = newProgressReadCloser(resp.Body, resp.ContentLength, func(transferred, _ int64) {
+		stats.BytesRead = transferred
+	})
+}
@@ -0,0 +1,56 @@
+package httprequest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_DoTimed(t *testing.T) {
+	expectedBytes, err := json.Marshal(resp1)
+	require.NoError(t, err)
+
+	t.Run("Single attempt", func(t *testing.T) {
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil).Once()
+
+		var out UserResponse
+		_, stats, err := New(http.MethodGet, testUrl, nil).DoTimed(context.Background(), mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Equal(t, 1, stats.Attempts)
+		assert.Equal(t, int64(len(expectedBytes)), stats.BytesRead)
+		assert.Positive(t, stats.Duration)
+	})
+	t.Run("Attempts include a retry", func(t *testing.T) {
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusServiceUnavailable, nil, nil).Once()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil).Once()
+
+		var out UserResponse
+		_, stats, err := New(http.MethodGet, testUrl, nil).
+			Retry(3, time.Millisecond).
+			DoTimed(context.Background(), mock, &out)
+		require.NoError(t, err)
+		assert.Equal(t, resp1, out)
+		assert.Equal(t, 2, stats.Attempts)
+		assert.Equal(t, int64(len(expectedBytes)), stats.BytesRead)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Stats are reported with an error", func(t *testing.T) {
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusServiceUnavailable, nil, nil).Times(2)
+
+		_, stats, err := New(http.MethodGet, testUrl, nil).
+			Retry(2, time.Millisecond).
+			DoTimed(context.Background(), mock, nil)
+		require.Error(t, err)
+		assert.Equal(t, 2, stats.Attempts)
+	})
+}
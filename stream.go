@@ -0,0 +1,184 @@
+package httprequest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// DoStream sends the request and validates the status code, but leaves the response body unread so it can be
+// consumed incrementally. The caller is responsible for closing the body.
+func (b *RequestBuilder) DoStream(ctx context.Context, doer Doer) (*http.Response, error) {
+	resp, err := b.send(ctx, doer)
+	if err != nil {
+		return nil, err
+	}
+
+	err = b.validateStatusCode(resp)
+	if err != nil {
+		drainAndClose(resp.Body)
+		return nil, err
+	}
+
+	if b.acceptGzip {
+		err = decompressStream(resp)
+		if err != nil {
+			drainAndClose(resp.Body)
+			return nil, err
+		}
+	}
+
+	if b.onDownloadProgress != nil {
+		resp.Body = newProgressReadCloser(resp.Body, resp.ContentLength, b.onDownloadProgress)
+	}
+
+	return resp, nil
+}
+
+// DoDownload sends the request and copies the response body into w, returning the number of bytes written.
+func (b *RequestBuilder) DoDownload(ctx context.Context, doer Doer, w io.Writer) (int64, *http.Response, error) {
+	resp, err := b.DoStream(ctx, doer)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	n, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return n, nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+
+	return n, resp, nil
+}
+
+// DoStreamArray sends the request and decodes a JSON array response one element at a time, calling fn with each
+// element in order without holding the whole array in memory. An error from fn stops decoding and is returned.
+func (b *RequestBuilder) DoStreamArray(ctx context.Context, doer Doer, fn func(json.RawMessage) error) (*http.Response, error) {
+	resp, err := b.DoStream(ctx, doer)
+	if err != nil {
+		return nil, err
+	}
+	defer drainAndClose(resp.Body)
+
+	dec := json.NewDecoder(resp.Body)
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '[' {
+		return nil, fmt.Errorf("expected a json array response, got %v", tok)
+	}
+
+	for dec.More() {
+		var elem json.RawMessage
+		err = dec.Decode(&elem)
+		if err != nil {
+			return nil, fmt.Errorf("unable to unmarshal json array element: %v", err)
+		}
+		err = fn(elem)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err = dec.Token()
+	if err != nil {
+		return nil, fmt.Errorf("unable to read response body: %v", err)
+	}
+
+	return resp, nil
+}
+
+// DoSSE sends the request and reads the response as a stream of Server-Sent Events, calling fn with the type and data
+// of each event as it arrives. Events without an event field have the type "message", and the lines of multi-line
+// data are joined with newlines. It returns when the stream ends, the context is done, or fn returns an error.
+func (b *RequestBuilder) DoSSE(ctx context.Context, doer Doer, fn func(event, data string) error) (*http.Response, error) {
+	resp, err := b.DoStream(ctx, doer)
+	if err != nil {
+		return nil, err
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get(HeaderContentType))
+	if mediaType != MIMETextEventStream {
+		drainAndClose(resp.Body)
+		return nil, fmt.Errorf("expected a %s response, got %q", MIMETextEventStream, resp.Header.Get(HeaderContentType))
+	}
+
+	// An event stream may never end, so the body is closed without draining it once the handler stops reading
+	defer resp.Body.Close()
+
+	// Not every Doer ties the body to the context, so closing it is what stops a read waiting on an idle stream
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = resp.Body.Close()
+		case <-stop:
+		}
+	}()
+
+	err = readEvents(resp.Body, fn)
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("unable to read response body: %w", ctx.Err())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// readEvents parses an event stream as described by the HTML Living Standard, calling fn for every complete event.
+func readEvents(r io.Reader, fn func(event, data string) error) error {
+	br := bufio.NewReader(r)
+	var event string
+	var data strings.Builder
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("unable to read response body: %v", err)
+		}
+		// An event that isn't terminated by a blank line before the stream ends is incomplete and discarded
+		if err == io.EOF {
+			return nil
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if data.Len() > 0 {
+				if event == "" {
+					event = "message"
+				}
+				cbErr := fn(event, strings.TrimSuffix(data.String(), "\n"))
+				if cbErr != nil {
+					return cbErr
+				}
+			}
+			event = ""
+			data.Reset()
+			continue
+		}
+
+		// Lines starting with a colon are comments, often sent to keep the connection alive
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data.WriteString(value)
+			data.WriteByte('\n')
+		}
+	}
+}
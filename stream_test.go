@@ -0,0 +1,178 @@
+package httprequest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestBuilder_DoStream(t *testing.T) {
+	t.Run("Body is left unread for the caller", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil)
+
+		resp, err := New(http.MethodGet, testUrl, nil).DoStream(ctx, mock)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var out UserResponse
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&out))
+		assert.Equal(t, resp1, out)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Unexpected status returns an error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusNotFound, nil, nil)
+
+		_, err := New(http.MethodGet, testUrl, nil).DoStream(ctx, mock)
+		require.Error(t, err)
+		mock.AssertExpectations(t)
+	})
+}
+
+func TestRequestBuilder_DoStreamArray(t *testing.T) {
+	t.Run("Callback is called once per element in order", func(t *testing.T) {
+		const count = 10000
+		users := make([]UserResponse, count)
+		for i := range users {
+			users[i] = UserResponse{ID: i, Name: "user"}
+		}
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, users, nil)
+
+		var ids []int
+		_, err := New(http.MethodGet, testUrl, nil).DoStreamArray(context.Background(), mock, func(elem json.RawMessage) error {
+			var user UserResponse
+			if err := json.Unmarshal(elem, &user); err != nil {
+				return err
+			}
+			ids = append(ids, user.ID)
+			return nil
+		})
+		require.NoError(t, err)
+		require.Len(t, ids, count)
+		for i, id := range ids {
+			assert.Equal(t, i, id)
+		}
+		mock.AssertExpectations(t)
+	})
+	t.Run("Callback error stops decoding", func(t *testing.T) {
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, []UserResponse{resp1, resp1, resp1}, nil)
+
+		stopErr := errors.New("stop")
+		var calls int
+		_, err := New(http.MethodGet, testUrl, nil).DoStreamArray(context.Background(), mock, func(elem json.RawMessage) error {
+			calls++
+			return stopErr
+		})
+		assert.Equal(t, stopErr, err)
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("Non-array response returns an error", func(t *testing.T) {
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil)
+
+		_, err := New(http.MethodGet, testUrl, nil).DoStreamArray(context.Background(), mock, func(elem json.RawMessage) error {
+			return nil
+		})
+		require.Error(t, err)
+	})
+}
+
+func TestRequestBuilder_DoSSE(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/json" {
+			w.Header().Set(HeaderContentType, MIMEApplicationJson)
+			_ = json.NewEncoder(w).Encode(resp1)
+			return
+		}
+
+		w.Header().Set(HeaderContentType, MIMETextEventStream)
+		for _, chunk := range []string{
+			": keep-alive\n\n",
+			"event: greeting\ndata: hello\n\n",
+			"data: line one\r\ndata: line two\r\n\r\n",
+		} {
+			_, _ = w.Write([]byte(chunk))
+			w.(http.Flusher).Flush()
+		}
+		if r.URL.Path == "/idle" {
+			<-release
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	type event struct{ name, data string }
+
+	t.Run("Handler receives every event", func(t *testing.T) {
+		var events []event
+		_, err := Get(server.URL).DoSSE(context.Background(), server.Client(), func(name, data string) error {
+			events = append(events, event{name, data})
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []event{{"greeting", "hello"}, {"message", "line one\nline two"}}, events)
+	})
+	t.Run("Cancelling the context stops an idle stream", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var events []event
+		_, err := Get(server.URL+"/idle").DoSSE(ctx, server.Client(), func(name, data string) error {
+			events = append(events, event{name, data})
+			if len(events) == 2 {
+				cancel()
+			}
+			return nil
+		})
+		assert.True(t, errors.Is(err, context.Canceled), "expected a context error, got %v", err)
+		assert.Len(t, events, 2)
+	})
+	t.Run("Handler error stops the stream", func(t *testing.T) {
+		stopErr := errors.New("stop")
+		var calls int
+		_, err := Get(server.URL).DoSSE(context.Background(), server.Client(), func(name, data string) error {
+			calls++
+			return stopErr
+		})
+		assert.Equal(t, stopErr, err)
+		assert.Equal(t, 1, calls)
+	})
+	t.Run("Handler error returns without waiting for an idle stream to end", func(t *testing.T) {
+		stopErr := errors.New("stop")
+		done := make(chan error, 1)
+		go func() {
+			_, err := Get(server.URL+"/idle").DoSSE(context.Background(), server.Client(), func(name, data string) error {
+				return stopErr
+			})
+			done <- err
+		}()
+
+		select {
+		case err := <-done:
+			assert.Equal(t, stopErr, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("DoSSE didn't return after the handler failed")
+		}
+	})
+	t.Run("Other content types return an error", func(t *testing.T) {
+		_, err := Get(server.URL+"/json").DoSSE(context.Background(), server.Client(), func(name, data string) error {
+			return nil
+		})
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "expected a text/event-stream response")
+	})
+}
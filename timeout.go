@@ -0,0 +1,35 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+type requestTimeoutKey struct{}
+
+// WithRequestTimeout returns a context that makes requests sent with it time out after d. The deadline covers the
+// whole exchange, including reading the response body, and starts when the request is sent rather than when the
+// context is created.
+func WithRequestTimeout(ctx context.Context, d time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, d)
+}
+
+func requestTimeout(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(requestTimeoutKey{}).(time.Duration)
+	return d, ok
+}
+
+// sendWithTimeout sends the request under the timeout carried by the context, releasing it once the response body
+// is closed.
+func (b *RequestBuilder) sendWithTimeout(ctx context.Context, doer Doer, d time.Duration) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	resp, err := b.sendAttempts(ctx, doer)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnCloseBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
@@ -0,0 +1,46 @@
+package httprequest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRequestTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	ctx := WithRequestTimeout(context.Background(), 50*time.Millisecond)
+	_, err := Get(server.URL).Do(ctx, server.Client(), nil)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestWithRequestTimeout_fastServer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HeaderContentType, MIMEApplicationJson)
+		_, _ = w.Write([]byte(`{"name":"Jack"}`))
+	}))
+	defer server.Close()
+
+	var out struct {
+		Name string `json:"name"`
+	}
+	ctx := WithRequestTimeout(context.Background(), time.Second)
+	_, err := Get(server.URL).Do(ctx, server.Client(), &out)
+	require.NoError(t, err)
+	assert.Equal(t, "Jack", out.Name)
+}
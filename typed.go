@@ -0,0 +1,18 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+)
+
+// DoTyped runs Do on the builder, decoding the response into a newly allocated T and returning it.
+func DoTyped[T any](ctx context.Context, b *RequestBuilder, doer Doer) (T, *http.Response, error) {
+	var out T
+	resp, err := b.Do(ctx, doer, &out)
+	if err != nil {
+		var zero T
+		return zero, nil, err
+	}
+
+	return out, resp, nil
+}
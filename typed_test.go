@@ -0,0 +1,37 @@
+package httprequest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/jackramey/httprequest/httpmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoTyped(t *testing.T) {
+	t.Run("Returns the decoded value", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusOK, resp1, nil)
+
+		out, resp, err := DoTyped[UserResponse](ctx, New(http.MethodGet, testUrl, nil), mock)
+		require.NoError(t, err)
+		require.NotEmpty(t, resp)
+		assert.Equal(t, resp1.ID, out.ID)
+		assert.Equal(t, resp1.Name, out.Name)
+		assert.Equal(t, resp1.IsAdmin, out.IsAdmin)
+		mock.AssertExpectations(t)
+	})
+	t.Run("Returns the zero value on error", func(t *testing.T) {
+		ctx := context.Background()
+		mock := httpmock.NewMock()
+		mock.GET(testUrl).Return(http.StatusNotFound, resp1, nil)
+
+		out, _, err := DoTyped[UserResponse](ctx, New(http.MethodGet, testUrl, nil), mock)
+		require.Error(t, err)
+		assert.Empty(t, out)
+		mock.AssertExpectations(t)
+	})
+}
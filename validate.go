@@ -0,0 +1,97 @@
+package httprequest
+
+import (
+	"fmt"
+	"mime"
+	"net/url"
+	"reflect"
+	"strings"
+)
+
+// Validate runs the checks Build would perform, without marshalling the body or constructing the request, so that a
+// misconfigured builder can be caught up front.
+func (b *RequestBuilder) Validate() error {
+	if b.err != nil {
+		return b.err
+	}
+
+	if !isValidMethod(b.httpMethod) {
+		return fmt.Errorf("invalid http method: %q", b.httpMethod)
+	}
+
+	u, err := url.Parse(b.resolveURL())
+	if err != nil {
+		return fmt.Errorf("unable to parse url: %v", err)
+	}
+	if !u.IsAbs() || u.Host == "" {
+		return fmt.Errorf("url must be absolute: %q", b.url)
+	}
+
+	if b.bodyFile != "" && b.signer != nil {
+		return errSignFileBody
+	}
+
+	// A body sent as query parameters is never marshalled, so only its query encoding can fail
+	if b.queryBody {
+		_, err = b.bodyQuery()
+		return err
+	}
+
+	if b.body == nil {
+		return nil
+	}
+	if _, ok := b.body.(BodyMarshaler); ok {
+		return nil
+	}
+
+	contentType, _, err := mime.ParseMediaType(b.contentType)
+	if err != nil {
+		return fmt.Errorf("unable to parse media type: %v", err)
+	}
+
+	if b.encoder != nil {
+		return nil
+	}
+
+	switch contentType {
+	case MIMEApplicationJson, MIMEMergePatchJson:
+		if kind := indirectKind(b.body); kind == reflect.Chan || kind == reflect.Func || kind == reflect.Complex64 ||
+			kind == reflect.Complex128 {
+			return fmt.Errorf("body of kind %s cannot be marshalled to json", kind)
+		}
+	case MIMEApplicationNDJSON:
+		if kind := reflect.TypeOf(b.body).Kind(); kind != reflect.Slice && kind != reflect.Array {
+			return fmt.Errorf("body of kind %s cannot be marshalled to ndjson", kind)
+		}
+	case MIMEApplicationXml, MIMETextXml:
+		if kind := indirectKind(b.body); kind == reflect.Map || kind == reflect.Chan || kind == reflect.Func {
+			return fmt.Errorf("body of kind %s cannot be marshalled to xml", kind)
+		}
+	case MIMETextCsv:
+		if _, ok := b.body.([][]string); !ok {
+			return fmt.Errorf("body of type %T cannot be marshalled to csv", b.body)
+		}
+	default:
+		return fmt.Errorf("unsupported content type: %s", contentType)
+	}
+
+	return nil
+}
+
+func indirectKind(v interface{}) reflect.Kind {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind()
+}
+
+// isValidMethod reports whether method is a valid HTTP token as defined by RFC 7230.
+func isValidMethod(method string) bool {
+	return method != "" && strings.IndexFunc(method, isNotTokenRune) == -1
+}
+
+func isNotTokenRune(r rune) bool {
+	isAlphaNum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+	return !isAlphaNum && !strings.ContainsRune("!#$%&'*+-.^_`|~", r)
+}
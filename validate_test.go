@@ -0,0 +1,96 @@
+package httprequest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestBuilder_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		builder *RequestBuilder
+		wantErr string
+	}{
+		{
+			name:    "Valid builder returns no error",
+			builder: New(http.MethodPost, testUrl, req1),
+		},
+		{
+			name:    "Valid builder without a body returns no error",
+			builder: New(http.MethodGet, testUrl, nil).ContentType("application/morse-code"),
+		},
+		{
+			name:    "Empty method returns an error",
+			builder: New("", testUrl, nil),
+			wantErr: "invalid http method",
+		},
+		{
+			name:    "Method with invalid characters returns an error",
+			builder: New("GET /", testUrl, nil),
+			wantErr: "invalid http method",
+		},
+		{
+			name:    "Unparseable url returns an error",
+			builder: New(http.MethodGet, "https://example.com/%zz", nil),
+			wantErr: "unable to parse url",
+		},
+		{
+			name:    "Relative url returns an error",
+			builder: New(http.MethodGet, "/api/v1/endpoint", nil),
+			wantErr: "url must be absolute",
+		},
+		{
+			name:    "Unparseable content type returns an error",
+			builder: New(http.MethodPost, testUrl, req1).ContentType("application/json;;"),
+			wantErr: "unable to parse media type",
+		},
+		{
+			name:    "Unsupported content type returns an error",
+			builder: New(http.MethodPost, testUrl, req1).ContentType("application/morse-code"),
+			wantErr: "unsupported content type",
+		},
+		{
+			name:    "Body incompatible with json returns an error",
+			builder: New(http.MethodPost, testUrl, make(chan int)),
+			wantErr: "cannot be marshalled to json",
+		},
+		{
+			name:    "Body incompatible with xml returns an error",
+			builder: New(http.MethodPost, testUrl, map[string]string{"a": "b"}).ContentType(MIMEApplicationXml),
+			wantErr: "cannot be marshalled to xml",
+		},
+		{
+			name:    "Invalid Referer returns an error",
+			builder: New(http.MethodGet, testUrl, nil).Referer("not a url"),
+			wantErr: "Referer",
+		},
+		{
+			name:    "Invalid QueryFrom value returns an error",
+			builder: New(http.MethodGet, testUrl, nil).QueryFrom("name=jack"),
+			wantErr: "unable to encode query",
+		},
+		{
+			name:    "Query body that can't be encoded returns an error",
+			builder: New(http.MethodGet, testUrl, []string{"jack"}).QueryBody(),
+			wantErr: "unable to encode query",
+		},
+		{
+			name:    "Query body isn't checked against the content type",
+			builder: New(http.MethodGet, testUrl, UserFilter{Name: "jack"}).ContentType("application/morse-code").QueryBody(),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.builder.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tt.wantErr)
+			}
+		})
+	}
+}